@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	d "github.com/deroholic/derogo"
+)
+
+// waitForCancel polls the book until order is gone.
+func waitForCancel(key string, order uint64) bool {
+	deadline := time.Now().Add(hopTimeout)
+
+	for time.Now().Before(deadline) {
+		pair, found := fetchTradePairs()[key]
+		if found {
+			if _, open := pair.orders[order]; !open {
+				return true
+			}
+		}
+		time.Sleep(time.Second)
+	}
+
+	return false
+}
+
+// tradeAmend replaces an open order with a new price and/or amount by
+// cancelling it and placing the new order once the cancel is confirmed.
+func tradeAmend(words []string) {
+	if len(words) < 3 || len(words) > 4 {
+		fmt.Println("amend requires 3 or 4 arguments")
+		tradeHelp()
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+	tokenA := tokens[symbols[0]]
+	tokenB := tokens[symbols[1]]
+
+	tx, err := strconv.Atoi(words[1])
+	if err != nil || tx <= 0 {
+		fmt.Println("invalid transaction number")
+		return
+	}
+
+	order, found := myOrders(pair)[uint64(tx)]
+	if !found {
+		fmt.Printf("order %d is not an open order of this wallet\n", tx)
+		return
+	}
+
+	// unchanged fields carry over, the amount left unfilled is what gets replaced
+	price_64 := pair.prices[order.n]
+	amt_64 := order.v1
+
+	for _, w := range words[2:] {
+		kv := strings.SplitN(w, "=", 2)
+		if len(kv) != 2 {
+			fmt.Printf("expected price=<p> or amount=<a>, not '%s'\n", w)
+			return
+		}
+
+		v_float, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil || v_float <= 0.0 {
+			fmt.Printf("cannot parse amount '%s'\n", kv[1])
+			return
+		}
+
+		switch strings.ToLower(kv[0]) {
+		case "price":
+			price_64 = uint64(v_float * priceScale)
+		case "amount":
+			amt_64 = uint64(v_float * math.Pow10(tokenA.decimals))
+		default:
+			fmt.Printf("expected price=<p> or amount=<a>, not '%s'\n", w)
+			return
+		}
+	}
+
+	if price_64 == 0 || amt_64 == 0 {
+		fmt.Println("amounts must be > 0.0")
+		return
+	}
+
+	if price_64 == pair.prices[order.n] && amt_64 == order.v1 {
+		fmt.Println("nothing to amend")
+		return
+	}
+
+	cancel_transfers, cancel_args := cancelOrder(uint64(tx))
+	transfers, args := sellOrder(words[0], amt_64, price_64)
+	refund := tokenA.contract
+	if order.t == "buy" {
+		transfers, args = buyOrder(words[0], amt_64, price_64)
+		refund = tokenB.contract
+	}
+
+	ge_cancel, ge_valid := d.DeroEstimateGas(pair.contract, cancel_transfers, cancel_args, 0)
+	if !ge_valid || ge_cancel.Status != "OK" {
+		fmt.Printf("Error: %+s\n", ge_cancel.Status)
+		return
+	}
+
+	// funds still locked in the order may make the estimate fail, it is
+	// estimated again once the cancel has refunded them
+	ge, ge_valid := d.DeroEstimateGas(pair.contract, transfers, args, 0)
+	estimated := ge_valid && ge.Status == "OK"
+
+	scale := math.Pow10(tokenA.decimals)
+	fmt.Printf("Amend %s %s order %d\n", words[0], order.t, tx)
+	fmt.Printf("  from %f %s @ %f %s\n", float64(order.v1)/scale, symbols[0], float64(pair.prices[order.n])/priceScale, symbols[1])
+	fmt.Printf("    to %f %s @ %f %s\n", float64(amt_64)/scale, symbols[0], float64(price_64)/priceScale, symbols[1])
+	if estimated {
+		fmt.Printf("Estimated fees: cancel %d, new order %d\n", ge_cancel.GasStorage, ge.GasStorage)
+	} else {
+		fmt.Printf("Estimated fees: cancel %d\n", ge_cancel.GasStorage)
+		fmt.Printf("Warning: the new order cannot be estimated yet (%s), it is checked again after the cancel\n", ge.Status)
+	}
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	before := d.DeroGetSCBal(refund)
+
+	txid, b := d.DeroSafeCallSC(pair.contract, cancel_transfers, cancel_args)
+	if !b {
+		fmt.Println("Cancel failed, the order is unchanged.")
+		return
+	}
+
+	fmt.Printf("Cancel submitted: txid = %s\n", txid)
+	markCancelled(words[0], uint64(tx))
+	fmt.Println("Waiting for the cancel to confirm...")
+
+	if !waitForCancel(words[0], uint64(tx)) {
+		fmt.Printf("Order %d is still on the book, the new order was not placed.\n", tx)
+		return
+	}
+
+	if order.v1 > 0 {
+		if _, arrived := waitForBalance(refund, before); !arrived {
+			fmt.Println("Cancel confirmed but the refund has not arrived, trying the new order anyway.")
+		}
+	}
+
+	retry := fmt.Sprintf("trade %s %s %f %f", order.t, words[0], float64(amt_64)/scale, float64(price_64)/priceScale)
+
+	ge, ge_valid = d.DeroEstimateGas(pair.contract, transfers, args, 0)
+	if !ge_valid || ge.Status != "OK" {
+		fmt.Printf("Error: %+s\n", ge.Status)
+		fmt.Printf("Order %d was CANCELLED but the new order was refused, nothing of it is on the book.\n", tx)
+		fmt.Printf("Place it again with: %s\n", retry)
+		return
+	}
+
+	txid, b = d.DeroSafeCallSC(pair.contract, transfers, args)
+	if !b {
+		fmt.Printf("Order %d was CANCELLED but the new order FAILED, nothing of it is on the book.\n", tx)
+		fmt.Printf("Place it again with: %s\n", retry)
+		return
+	}
+
+	fmt.Printf("New order submitted: txid = %s, fees = %d\n", txid, ge.GasStorage)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/holiman/uint256"
+)
+
+// Constant product math for the swap pairs. Every step is a truncating
+// integer division in the same order as the pair contract, so the amounts
+// shown in previews are the amounts the contract will produce.
+
+// fees are expressed in basis points
+const feeDenom = 10000
+
+// ammAmountOut is the output of swapping amt into a pool holding valIn/valOut.
+// The fee is taken from the output.
+func ammAmountOut(amt uint64, valIn uint64, valOut uint64, fee uint64) uint64 {
+	if valIn == 0 || valOut == 0 || fee >= feeDenom {
+		return 0
+	}
+
+	A := uint256.NewInt(amt)
+	out := new(uint256.Int).Mul(A, uint256.NewInt(valOut))
+	out.Div(out, new(uint256.Int).Add(uint256.NewInt(valIn), A))
+
+	out.Mul(out, uint256.NewInt(feeDenom-fee))
+	out.Div(out, uint256.NewInt(feeDenom))
+
+	return out.Uint64()
+}
+
+// ammAmountIn is the smallest input for which ammAmountOut yields at least amt.
+func ammAmountIn(amt uint64, valIn uint64, valOut uint64, fee uint64) (uint64, bool) {
+	if amt == 0 || valIn == 0 || valOut == 0 || fee >= feeDenom {
+		return 0, false
+	}
+
+	// output before the fee, rounded up
+	gross := new(uint256.Int).Mul(uint256.NewInt(amt), uint256.NewInt(feeDenom))
+	gross.Add(gross, uint256.NewInt(feeDenom-fee-1))
+	gross.Div(gross, uint256.NewInt(feeDenom-fee))
+
+	if !gross.Lt(uint256.NewInt(valOut)) {
+		return 0, false
+	}
+
+	// input for the gross output, rounded up
+	rem := new(uint256.Int).Sub(uint256.NewInt(valOut), gross)
+	in := new(uint256.Int).Mul(gross, uint256.NewInt(valIn))
+	in.Add(in, new(uint256.Int).SubUint64(rem, 1))
+	in.Div(in, rem)
+
+	if !in.IsUint64() {
+		return 0, false
+	}
+
+	// the truncated fee can leave the estimate a unit off either way
+	n := in.Uint64()
+	for n > 1 && ammAmountOut(n-1, valIn, valOut, fee) >= amt {
+		n--
+	}
+	for ammAmountOut(n, valIn, valOut, fee) < amt {
+		n++
+	}
+
+	return n, true
+}
+
+// ammLiquidityPair is the amount of the other token that matches amt at the pool ratio.
+func ammLiquidityPair(amt uint64, val uint64, valOther uint64) uint64 {
+	if val == 0 {
+		return 0
+	}
+
+	return multDiv(amt, valOther, val)
+}
+
+// ammMintShares is the number of shares minted for depositing amt1/amt2.
+// The first deposit mints the geometric mean of the two amounts.
+func ammMintShares(amt1 uint64, amt2 uint64, val1 uint64, val2 uint64, outstanding uint64) uint64 {
+	if outstanding == 0 || val1 == 0 || val2 == 0 {
+		shares := new(uint256.Int).Mul(uint256.NewInt(amt1), uint256.NewInt(amt2))
+		return shares.Sqrt(shares).Uint64()
+	}
+
+	shares1 := multDiv(amt1, outstanding, val1)
+	shares2 := multDiv(amt2, outstanding, val2)
+
+	if shares2 < shares1 {
+		return shares2
+	}
+	return shares1
+}
+
+// ammBurnShares is the amount of each token returned for burning shares.
+func ammBurnShares(shares uint64, val1 uint64, val2 uint64, outstanding uint64) (uint64, uint64) {
+	if outstanding == 0 {
+		return 0, 0
+	}
+
+	return multDiv(val1, shares, outstanding), multDiv(val2, shares, outstanding)
+}
+
+// ammZapSwap is the part of a single sided deposit of amt to swap first, so
+// that the remainder and the swap output match the pool ratio after the swap.
+func ammZapSwap(amt uint64, valIn uint64, valOut uint64, fee uint64) uint64 {
+	lo, hi := uint64(0), amt
+
+	// largest swap for which the remainder still covers the output
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		out := ammAmountOut(mid, valIn, valOut, fee)
+
+		left := new(uint256.Int).Mul(uint256.NewInt(amt-mid), uint256.NewInt(valOut-out))
+		right := new(uint256.Int).Mul(uint256.NewInt(out), new(uint256.Int).Add(uint256.NewInt(valIn), uint256.NewInt(mid)))
+
+		if left.Lt(right) {
+			hi = mid - 1
+		} else {
+			lo = mid
+		}
+	}
+
+	return lo
+}
+
+// ammPriceImpact is the percentage the pool price moves for an input of amt.
+func ammPriceImpact(amt uint64, valIn uint64) float64 {
+	if valIn == 0 {
+		return 100.0
+	}
+
+	return float64(amt) / (float64(valIn) + float64(amt)) * 100.0
+}
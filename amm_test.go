@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+)
+
+// Expected values follow the pair contract: out = amt*valOut/(valIn+amt),
+// then out*(10000-fee)/10000, each division truncating.
+
+const max64 = ^uint64(0)
+
+func TestAmmAmountOut(t *testing.T) {
+	tests := []struct {
+		name                    string
+		amt, valIn, valOut, fee uint64
+		want                    uint64
+	}{
+		{"no input reserve", 100, 0, 1000, 30, 0},
+		{"no output reserve", 100, 1000, 0, 30, 0},
+		{"fee of 100%", 100, 1000, 1000, 10000, 0},
+		{"no fee", 1000, 10000, 20000, 0, 1818},
+		{"fee", 1000, 10000, 20000, 30, 1812},
+		{"fee rounds to zero", 1, 1, 3, 30, 0},
+		{"near 2^64 no fee", max64, max64, max64, 0, 9223372036854775807},
+		{"near 2^64 fee", max64, max64, max64, 30, 9195701920744211479},
+		{"large reserves", 1000000000000000000, max64, max64 / 2, 25, 473103033180795017},
+	}
+
+	for _, tt := range tests {
+		if got := ammAmountOut(tt.amt, tt.valIn, tt.valOut, tt.fee); got != tt.want {
+			t.Errorf("%s: ammAmountOut(%d, %d, %d, %d) = %d, want %d", tt.name, tt.amt, tt.valIn, tt.valOut, tt.fee, got, tt.want)
+		}
+	}
+}
+
+func TestAmmAmountIn(t *testing.T) {
+	tests := []struct {
+		name                    string
+		amt, valIn, valOut, fee uint64
+		want                    uint64
+		ok                      bool
+	}{
+		{"no reserves", 100, 0, 0, 30, 0, false},
+		{"zero output", 0, 10000, 20000, 30, 0, false},
+		{"exact", 1812, 10000, 20000, 30, 1000, true},
+		{"one unit more", 1813, 10000, 20000, 30, 1001, true},
+		{"fee rounding", 1, 1, 3, 30, 2, true},
+		{"drains the pool", 19999, 10000, 20000, 30, 0, false},
+		{"near 2^64", 1000000000000000000, max64, max64, 30, 1060681716199559461, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := ammAmountIn(tt.amt, tt.valIn, tt.valOut, tt.fee)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: ammAmountIn(%d, %d, %d, %d) = %d, %v, want %d, %v", tt.name, tt.amt, tt.valIn, tt.valOut, tt.fee, got, ok, tt.want, tt.ok)
+			continue
+		}
+
+		// the input is the smallest that buys amt
+		if ok && (ammAmountOut(got, tt.valIn, tt.valOut, tt.fee) < tt.amt || ammAmountOut(got-1, tt.valIn, tt.valOut, tt.fee) >= tt.amt) {
+			t.Errorf("%s: ammAmountIn(%d, %d, %d, %d) = %d is not the smallest input", tt.name, tt.amt, tt.valIn, tt.valOut, tt.fee, got)
+		}
+	}
+}
+
+func TestAmmMintShares(t *testing.T) {
+	tests := []struct {
+		name                                string
+		amt1, amt2, val1, val2, outstanding uint64
+		want                                uint64
+	}{
+		{"first deposit", 4, 9, 0, 0, 0, 6},
+		{"first deposit rounds down", 10, 20, 0, 0, 0, 14},
+		{"first deposit near 2^64", max64, max64, 0, 0, 0, max64},
+		{"empty reserves", 4, 9, 0, 100, 100, 6},
+		{"smaller side counts", 100, 300, 1000, 2000, 500, 50},
+		{"truncates", 1, 1, 3, 3, 1, 0},
+		{"near 2^64", max64 / 2, max64 / 2, max64, max64, max64, max64 / 2},
+	}
+
+	for _, tt := range tests {
+		if got := ammMintShares(tt.amt1, tt.amt2, tt.val1, tt.val2, tt.outstanding); got != tt.want {
+			t.Errorf("%s: ammMintShares(%d, %d, %d, %d, %d) = %d, want %d", tt.name, tt.amt1, tt.amt2, tt.val1, tt.val2, tt.outstanding, got, tt.want)
+		}
+	}
+}
+
+func TestAmmBurnShares(t *testing.T) {
+	tests := []struct {
+		name                            string
+		shares, val1, val2, outstanding uint64
+		want1, want2                    uint64
+	}{
+		{"no shares outstanding", 10, 1000, 2000, 0, 0, 0},
+		{"proportional", 50, 1000, 2000, 500, 100, 200},
+		{"truncates", 1, 10, 10, 3, 3, 3},
+		{"all shares", 500, 1000, 2000, 500, 1000, 2000},
+		{"near 2^64", max64, max64, max64, max64, max64, max64},
+	}
+
+	for _, tt := range tests {
+		got1, got2 := ammBurnShares(tt.shares, tt.val1, tt.val2, tt.outstanding)
+		if got1 != tt.want1 || got2 != tt.want2 {
+			t.Errorf("%s: ammBurnShares(%d, %d, %d, %d) = %d, %d, want %d, %d", tt.name, tt.shares, tt.val1, tt.val2, tt.outstanding, got1, got2, tt.want1, tt.want2)
+		}
+	}
+}
+
+func TestAmmZapSwap(t *testing.T) {
+	tests := []struct {
+		name                    string
+		amt, valIn, valOut, fee uint64
+		want                    uint64
+	}{
+		{"no fee", 1000, 10000, 20000, 0, 488},
+		{"fee", 1000, 10000, 20000, 30, 489},
+		{"single unit", 1, 10, 10, 30, 1},
+		{"large reserves", 1000000000000000000, max64 / 2, max64 / 4, 30, 487906350232173215},
+	}
+
+	for _, tt := range tests {
+		if got := ammZapSwap(tt.amt, tt.valIn, tt.valOut, tt.fee); got != tt.want {
+			t.Errorf("%s: ammZapSwap(%d, %d, %d, %d) = %d, want %d", tt.name, tt.amt, tt.valIn, tt.valOut, tt.fee, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	d "github.com/deroholic/derogo"
+	"github.com/deroproject/derohe/rpc"
+)
+
+// arbOpp is the most profitable trade between a swap pair and the order
+// book of the same symbols. Amounts are in the first symbol of the trade
+// pair, cost, proceeds and profit in the second.
+type arbOpp struct {
+	key      string
+	swapKey  string
+	buyBook  bool // buy on the book and sell to the pool, or the other way around
+	amount   uint64
+	cost     uint64
+	proceeds uint64
+	limit    uint64 // book price that fills amount
+	pool     float64
+	bid      float64
+	ask      float64
+}
+
+func (a arbOpp) profit() int64 {
+	return int64(a.proceeds) - int64(a.cost)
+}
+
+func (a arbOpp) action() string {
+	if a.buyBook {
+		return "buy book, sell pool"
+	}
+	return "buy pool, sell book"
+}
+
+// poolPrice is the marginal price of the first symbol of key in the second,
+// scaled like book prices.
+func poolPrice(swapKey string, key string) float64 {
+	symbols := strings.Split(key, ":")
+	valIn, valOut := poolReserves(swapKey, symbols[0])
+	if valIn == 0 {
+		return 0
+	}
+
+	return float64(valOut) / float64(valIn) * math.Pow10(tokens[symbols[0]].decimals-tokens[symbols[1]].decimals) * priceScale
+}
+
+// bookValue is what amt of the first symbol trades for against side of the
+// book before the fee, with the price of the last level taken.
+func bookValue(pair TradePair, key string, side string, amt uint64) (value uint64, limit uint64, ok bool) {
+	symbols := strings.Split(key, ":")
+
+	fills, filled := bookFills(pair, side, amt, 0)
+	if filled < amt || len(fills) == 0 {
+		return
+	}
+
+	for _, f := range fills {
+		value += orderCost(f.amount, f.price, tokens[symbols[0]].decimals, tokens[symbols[1]].decimals)
+	}
+
+	return value, fills[len(fills)-1].price, true
+}
+
+// arbTrade prices a round trip of amt through the book and the pool.
+func arbTrade(pair TradePair, key string, swapKey string, buyBook bool, amt uint64) (a arbOpp, ok bool) {
+	symbols := strings.Split(key, ":")
+	a = arbOpp{key: key, swapKey: swapKey, buyBook: buyBook, amount: amt}
+
+	if buyBook {
+		value, limit, found := bookValue(pair, key, "sell", amt)
+		if !found {
+			return
+		}
+		a.cost = value + multDiv(value, pair.fee, 10000)
+		a.proceeds = swapOutput(swapKey, symbols[0], amt)
+		a.limit = limit
+		return a, true
+	}
+
+	value, limit, found := bookValue(pair, key, "buy", amt)
+	if !found {
+		return
+	}
+	cost, found := swapInput(swapKey, symbols[0], amt)
+	if !found {
+		return
+	}
+	a.cost = cost
+	a.proceeds = value - multDiv(value, pair.fee, 10000)
+	a.limit = limit
+	return a, true
+}
+
+// othersBook is pair without the wallet's own orders, which arb must not
+// count as liquidity.
+func othersBook(pair TradePair) TradePair {
+	mine := myOrders(pair)
+
+	others := pair
+	others.orders = make(map[uint64]Order)
+	for k, o := range pair.orders {
+		if _, own := mine[k]; !own {
+			others.orders[k] = o
+		}
+	}
+
+	return others
+}
+
+// ownCrossed reports whether an order of the wallet on side is at or inside
+// limit, a book leg with that limit would match it first.
+func ownCrossed(pair TradePair, side string, limit uint64) bool {
+	for _, o := range myOrders(pair) {
+		price := pair.prices[o.n]
+		if o.t == side && ((side == "sell" && price <= limit) || (side == "buy" && price >= limit)) {
+			return true
+		}
+	}
+	return false
+}
+
+// bestArb searches the most profitable size in both directions. Profit is
+// concave in the size, the pool pays less and the book charges more the
+// more is traded, so a ternary search finds the top.
+func bestArb(pair TradePair, key string, swapKey string) (best arbOpp, found bool) {
+	for _, buyBook := range []bool{true, false} {
+		side := "buy"
+		if buyBook {
+			side = "sell"
+		}
+
+		levels := bookLevels(pair, side)
+		if len(levels) == 0 {
+			continue
+		}
+
+		profit := func(amt uint64) int64 {
+			a, ok := arbTrade(pair, key, swapKey, buyBook, amt)
+			if !ok {
+				return math.MinInt64
+			}
+			return a.profit()
+		}
+
+		lo, hi := uint64(1), levels[len(levels)-1].total
+		for hi-lo > 2 {
+			m1 := lo + (hi-lo)/3
+			m2 := hi - (hi-lo)/3
+			if profit(m1) < profit(m2) {
+				lo = m1 + 1
+			} else {
+				hi = m2
+			}
+		}
+
+		for amt := lo; amt <= hi; amt++ {
+			a, ok := arbTrade(pair, key, swapKey, buyBook, amt)
+			if ok && a.profit() > 0 && (!found || a.profit() > best.profit()) {
+				best, found = a, true
+			}
+		}
+	}
+
+	if found {
+		best.pool = poolPrice(swapKey, key)
+		if bids := bookLevels(pair, "buy"); len(bids) > 0 {
+			best.bid = float64(bids[0].price)
+		}
+		if asks := bookLevels(pair, "sell"); len(asks) > 0 {
+			best.ask = float64(asks[0].price)
+		}
+	}
+	return
+}
+
+// scanArb finds an opportunity for every trade pair that also has a pool.
+func scanArb() (opps []arbOpp) {
+	getPairs()
+	getTradePairs()
+
+	for key, pair := range tradePairs {
+		symbols := strings.Split(key, ":")
+		swapKey, found := findPair(symbols[0], symbols[1])
+		if !found || pairs[swapKey].val1 == 0 || pairs[swapKey].val2 == 0 {
+			continue
+		}
+
+		if a, found := bestArb(othersBook(pair), key, swapKey); found {
+			opps = append(opps, a)
+		}
+	}
+
+	sort.Slice(opps, func(i, j int) bool { return opps[i].key < opps[j].key })
+	return
+}
+
+func arb(words []string) {
+	if len(words) > 0 && words[0] == "cycles" {
+		arbCycles(words[1:])
+		return
+	}
+
+	if len(words) == 2 && words[0] == "execute" {
+		arbExecute(words[1])
+		return
+	}
+
+	if len(words) != 0 {
+		fmt.Println("arb requires 0 or 2 arguments")
+		printHelp()
+		return
+	}
+
+	opps := scanArb()
+	if len(opps) == 0 {
+		fmt.Println("No profitable arbitrage between pools and order books")
+		return
+	}
+
+	fmt.Printf("%-20s %15s %15s %15s %-20s %19s %19s %12s\n\n", "PAIR", "POOL", "BID", "ASK", "ACTION", "SIZE", "PROFIT", "PROFIT USDT")
+	for _, a := range opps {
+		symbols := strings.Split(a.key, ":")
+		profit := float64(a.profit()) / math.Pow10(tokens[symbols[1]].decimals)
+		ratio, _ := conversion(symbols[1], "DUSDT")
+
+		fmt.Printf("%-20s %15f %15f %15f %-20s %19f %19f %12.2f\n", a.key, a.pool/priceScale, a.bid/priceScale, a.ask/priceScale,
+			a.action(), float64(a.amount)/math.Pow10(tokens[symbols[0]].decimals), profit, profit*ratio)
+	}
+	fmt.Println()
+	fmt.Println("Profits are after pool and book fees, run 'arb execute <pair>' to trade one")
+}
+
+// arbExecute trades the opportunity of one pair, the first leg is waited for
+// before the second is sent.
+func arbExecute(key string) {
+	getPairs()
+	getTradePairs()
+
+	pair := tradePairs[key]
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", key)
+		return
+	}
+
+	symbols := strings.Split(key, ":")
+	swapKey, found := findPair(symbols[0], symbols[1])
+	if !found {
+		fmt.Printf("no swap pair for '%s'\n", key)
+		return
+	}
+
+	a, found := bestArb(othersBook(pair), key, swapKey)
+	if !found {
+		fmt.Printf("no profitable arbitrage on '%s'\n", key)
+		return
+	}
+
+	// the book leg is limited to the levels priced in, the rest would rest
+	tokA, tokB := tokens[symbols[0]], tokens[symbols[1]]
+	limit := a.limit
+
+	side := "buy"
+	if a.buyBook {
+		side = "sell"
+	}
+	if ownCrossed(pair, side, limit) {
+		fmt.Printf("Your own %s orders on '%s' are within the limit and would be matched first, cancel them to trade this.\n", side, key)
+		return
+	}
+
+	var transfers []rpc.Transfer
+	var args rpc.Arguments
+	if a.buyBook {
+		transfers, args = buyOrder(key, a.amount, limit)
+		ge, ge_valid := d.DeroEstimateGas(pair.contract, transfers, args, 0)
+		if !ge_valid || ge.Status != "OK" {
+			fmt.Printf("Error: %+s\n", ge.Status)
+			return
+		}
+	}
+
+	if a.buyBook {
+		fmt.Printf("1) buy %f %s on the book, limit %f %s\n", d.DeroFormatMoneyPrecision(a.amount, tokA.decimals), symbols[0], float64(limit)/priceScale, symbols[1])
+		fmt.Printf("2) swap it on %s for %f %s\n", swapKey, d.DeroFormatMoneyPrecision(a.proceeds, tokB.decimals), symbols[1])
+	} else {
+		fmt.Printf("1) swap %f %s on %s for %f %s\n", d.DeroFormatMoneyPrecision(a.cost, tokB.decimals), symbols[1], swapKey,
+			d.DeroFormatMoneyPrecision(a.amount, tokA.decimals), symbols[0])
+		fmt.Printf("2) sell it on the book, limit %f %s\n", float64(limit)/priceScale, symbols[1])
+	}
+	fmt.Printf("Expected profit %f %s, the pool leg is held to %.2f%% slippage\n",
+		d.DeroFormatMoneyPrecision(uint64(a.profit()), tokB.decimals), symbols[1], maxSlippage)
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	before := d.DeroGetSCBal(tokA.contract)
+	highest := highestOrder(pair)
+
+	var txid string
+	var b bool
+	if a.buyBook {
+		txid, b = d.DeroSafeCallSC(pair.contract, transfers, args)
+	} else {
+		getPairs()
+
+		if swapOutput(swapKey, symbols[1], a.cost) < minReceived(a.amount, maxSlippage) {
+			fmt.Printf("Reserves of %s moved past the slippage tolerance, nothing traded.\n", swapKey)
+			return
+		}
+
+		txid, b = callSwap(swapKey, symbols[1], a.cost)
+	}
+	if !b {
+		fmt.Println("First leg failed, nothing traded.")
+		return
+	}
+
+	fmt.Printf("First leg submitted: txid = %s\n", txid)
+	fmt.Printf("Waiting for %s to arrive...\n", symbols[0])
+
+	after, arrived := waitForBalance(tokA.contract, before)
+	if a.buyBook {
+		if arrived {
+			fmt.Printf("Bought %f of %f %s\n", d.DeroFormatMoneyPrecision(after-before, tokA.decimals),
+				d.DeroFormatMoneyPrecision(a.amount, tokA.decimals), symbols[0])
+		}
+		settleBookLeg(key, pair.contract, "buy", a.amount, limit, highest)
+	}
+	if !arrived {
+		fmt.Println("Timed out waiting for the first leg, second leg not sent.")
+		return
+	}
+	amt := after - before
+	beforeB := d.DeroGetSCBal(tokB.contract)
+
+	if a.buyBook {
+		getPairs()
+
+		minimum := minReceived(multDiv(a.proceeds, amt, a.amount), maxSlippage)
+		if swapOutput(swapKey, symbols[0], amt) < minimum {
+			fmt.Printf("Reserves of %s moved past the slippage tolerance, holding %f %s\n", swapKey,
+				d.DeroFormatMoneyPrecision(amt, tokA.decimals), symbols[0])
+			return
+		}
+
+		txid, b = callSwap(swapKey, symbols[0], amt)
+	} else {
+		transfers, args = sellOrder(key, amt, limit)
+		ge, ge_valid := d.DeroEstimateGas(pair.contract, transfers, args, 0)
+		if !ge_valid || ge.Status != "OK" {
+			fmt.Printf("Error: %+s\n", ge.Status)
+			fmt.Printf("Second leg not sent, holding %f %s\n", d.DeroFormatMoneyPrecision(amt, tokA.decimals), symbols[0])
+			return
+		}
+
+		txid, b = d.DeroSafeCallSC(pair.contract, transfers, args)
+	}
+	if !b {
+		fmt.Printf("Second leg failed, holding %f %s\n", d.DeroFormatMoneyPrecision(amt, tokA.decimals), symbols[0])
+		return
+	}
+
+	fmt.Printf("Second leg submitted: txid = %s\n", txid)
+
+	if !a.buyBook {
+		fmt.Printf("Waiting for %s to arrive...\n", symbols[1])
+		if afterB, arrived := waitForBalance(tokB.contract, beforeB); arrived {
+			fmt.Printf("Received %f %s\n", d.DeroFormatMoneyPrecision(afterB-beforeB, tokB.decimals), symbols[1])
+		}
+		settleBookLeg(key, pair.contract, "sell", amt, limit, highest)
+	}
+}
+
+// highestOrder is the highest order number on the book of pair, orders
+// placed later have higher numbers.
+func highestOrder(pair TradePair) (highest uint64) {
+	for k := range pair.orders {
+		if k > highest {
+			highest = k
+		}
+	}
+	return
+}
+
+// settleBookLeg cancels the part of a book leg that did not fill and now
+// rests on the book, found as the wallet's order of side, amt and price
+// placed after the order number after.
+func settleBookLeg(key string, contract string, side string, amt uint64, price uint64, after uint64) {
+	pair, found := fetchTradePairs()[key]
+	if !found {
+		return
+	}
+
+	symbols := strings.Split(key, ":")
+	for k, o := range myOrders(pair) {
+		if k <= after || o.t != side || o.o1 != amt || pair.prices[o.n] != price || o.v1 == 0 {
+			continue
+		}
+
+		fmt.Printf("Order %d rests on the book with %f of %f %s unfilled, cancelling it\n", k,
+			d.DeroFormatMoneyPrecision(o.v1, tokens[symbols[0]].decimals), d.DeroFormatMoneyPrecision(amt, tokens[symbols[0]].decimals), symbols[0])
+
+		transfers, args := cancelOrder(k)
+		txid, b := d.DeroSafeCallSC(contract, transfers, args)
+		if !b {
+			fmt.Printf("Cancel failed, order %d stays on the book, cancel it with: trade cancel %s %d\n", k, key, k)
+			return
+		}
+		fmt.Printf("Cancel of order %d submitted: txid = %s\n", k, txid)
+		markCancelled(key, k)
+		return
+	}
+}
@@ -1,15 +1,52 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
 
 	d "github.com/deroholic/derogo"
+	"github.com/deroproject/derohe/cryptography/crypto"
 	"github.com/deroproject/derohe/rpc"
 )
 
 var bridgeRegistry string
 
+// checksumAddress returns the EIP-55 form of an Ethereum address, which must
+// be 0x followed by 20 bytes of hex.
+func checksumAddress(eth_addr string) (string, bool) {
+	if len(eth_addr) != 42 || !strings.HasPrefix(eth_addr, "0x") {
+		return "", false
+	}
+
+	lower := strings.ToLower(eth_addr[2:])
+	if _, err := hex.DecodeString(lower); err != nil {
+		return "", false
+	}
+
+	// a letter is upper case when its nibble of the hash of the lower case address is >= 8
+	hash := crypto.Keccak256([]byte(lower))
+	sum := []byte(lower)
+	for i, c := range sum {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && c <= 'f' && nibble >= 8 {
+			sum[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(sum), true
+}
+
+// hasChecksum reports whether an address is in mixed case, all lower or all
+// upper case addresses carry no checksum.
+func hasChecksum(eth_addr string) bool {
+	digits := strings.TrimPrefix(eth_addr, "0x")
+	return digits != strings.ToLower(digits) && digits != strings.ToUpper(digits)
+}
+
 func callBridge(scid string, eth_addr string, amount uint64, fee uint64) bool {
         var transfers []rpc.Transfer
         transfers = d.DeroBuildTransfers(transfers, scid, "", 0, amount)
@@ -55,16 +92,31 @@ func bridge(words []string) {
 		return
 	}
 
-	if words[1] == strings.ToLower(words[1]) || words[1] == strings.ToUpper(words[1]) {
-		fmt.Printf("Ethereum address must be in CamelCase (mixed case) not all lower or all upper.\n")
-		fmt.Printf("Please check and try again with a different address format.\n")
+	eth_addr, ok := checksumAddress(words[1])
+	if !ok {
+		fmt.Printf("Invalid Ethereum address '%s', expected 0x followed by 40 hex digits.\n", words[1])
 		return
 	}
 
-	fmt.Printf("Transfer %f %s to Ethereum address %s\n", d.DeroFormatMoneyPrecision(amount, tokens[token].decimals), token, words[1])
+	if words[1] != eth_addr {
+		if hasChecksum(words[1]) {
+			fmt.Printf("Ethereum address '%s' fails its checksum, check it for typos.\n", words[1])
+			return
+		}
+
+		// an all lower or all upper case address carries no checksum to verify
+		fmt.Printf("Ethereum address has no checksum, its checksummed form is %s\n", eth_addr)
+		fmt.Println("Make sure this is the address you intend to send to.")
+		if !askContinue() {
+			fmt.Println("aborting...")
+			return
+		}
+	}
+
+	fmt.Printf("Transfer %f %s to Ethereum address %s\n", d.DeroFormatMoneyPrecision(amount, tokens[token].decimals), token, eth_addr)
 	fmt.Printf("Bridge fee %f DERO\n", d.DeroFormatMoneyPrecision(tokens[token].bridgeFee, 5))
 
 	if askContinue() {
-		callBridge(scid, words[1], amount, tokens[token].bridgeFee)
+		callBridge(scid, eth_addr, amount, tokens[token].bridgeFee)
 	}
 }
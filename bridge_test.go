@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Vectors from EIP-55.
+func TestChecksumAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		addr   string
+		want   string
+		ok     bool
+		usable bool // accepted as is or converted after a warning
+	}{
+		{"all caps", "0x52908400098527886E0F7030069857D2E4169EE7", "0x52908400098527886E0F7030069857D2E4169EE7", true, true},
+		{"all caps", "0x8617E340B3D01FA5F11F306F4090FD50E238070D", "0x8617E340B3D01FA5F11F306F4090FD50E238070D", true, true},
+		{"all lower", "0xde709f2102306220921060314715629080e2fb77", "0xde709f2102306220921060314715629080e2fb77", true, true},
+		{"all lower", "0x27b1fdb04752bbc536007a920d24acb045561c26", "0x27b1fdb04752bbc536007a920d24acb045561c26", true, true},
+		{"mixed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", true, true},
+		{"mixed", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", true, true},
+		{"mixed", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", true, true},
+		{"mixed", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", true, true},
+		{"lower case converted", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", true, true},
+		{"upper case converted", "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", true, true},
+		{"one letter flipped", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", true, false},
+		{"too short", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", "", false, false},
+		{"too long", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed00", "", false, false},
+		{"missing 0x", "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "", false, false},
+		{"missing 0x padded", "005aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "", false, false},
+		{"not hex", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg", "", false, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := checksumAddress(tt.addr)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: checksumAddress(%s) = %s, %v, want %s, %v", tt.name, tt.addr, got, ok, tt.want, tt.ok)
+			continue
+		}
+
+		// the same decision bridge makes
+		usable := ok && (got == tt.addr || !hasChecksum(tt.addr))
+		if usable != tt.usable {
+			t.Errorf("%s: %s usable = %v, want %v", tt.name, tt.addr, usable, tt.usable)
+		}
+	}
+}
+
+func TestHasChecksum(t *testing.T) {
+	addr := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr, true},
+		{strings.ToLower(addr), false},
+		{"0x" + strings.ToUpper(addr[2:]), false},
+		{"0x0000000000000000000000000000000000000000", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasChecksum(tt.addr); got != tt.want {
+			t.Errorf("hasChecksum(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
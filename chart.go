@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chart size in candles and rows
+const (
+	chartWidth  = 60
+	chartHeight = 20
+)
+
+// exports cover the whole history up to this many candles
+const exportWidth = 10000
+
+type candle struct {
+	start  int64
+	open   uint64
+	high   uint64
+	low    uint64
+	close  uint64
+	volume uint64
+	trades int
+}
+
+// parseInterval reads a candle interval such as 1m, 1h or 1d.
+func parseInterval(s string) (time.Duration, bool) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, false
+		}
+		return time.Duration(days) * 24 * time.Hour, true
+	}
+
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval < time.Minute {
+		return 0, false
+	}
+	return interval, true
+}
+
+// candles aggregates trade history into OHLCV candles of interval from the
+// interval holding from up to to. Intervals without trades repeat the
+// previous close with no volume, those before the first known price are left
+// out.
+func candles(hist []Hist, interval time.Duration, from int64, to int64) (out []candle) {
+	sort.Slice(hist, func(i, j int) bool { return hist[i].timestamp < hist[j].timestamp })
+
+	step := int64(interval.Seconds())
+	from = from / step * step
+
+	// the last trade before the window is where it opens
+	var prev uint64
+	known := false
+	i := 0
+	for ; i < len(hist) && int64(hist[i].timestamp) < from; i++ {
+		prev, known = hist[i].v2, true
+	}
+
+	for t := from; t < to; t += step {
+		var c candle
+		for ; i < len(hist) && int64(hist[i].timestamp) < t+step; i++ {
+			h := hist[i]
+			if c.trades == 0 {
+				c = candle{t, h.v2, h.v2, h.v2, h.v2, 0, 0}
+			}
+			if h.v2 > c.high {
+				c.high = h.v2
+			}
+			if h.v2 < c.low {
+				c.low = h.v2
+			}
+			c.close = h.v2
+			c.volume += h.v1
+			c.trades++
+		}
+
+		if c.trades == 0 {
+			if !known {
+				continue
+			}
+			c = candle{t, prev, prev, prev, prev, 0, 0}
+		}
+
+		prev, known = c.close, true
+		out = append(out, c)
+	}
+
+	return
+}
+
+// printChart draws one column per candle as ASCII candlesticks, '#' rising and 'X' falling.
+func printChart(cs []candle, symbols []string) {
+	low, high := cs[0].low, cs[0].high
+	for _, c := range cs {
+		if c.low < low {
+			low = c.low
+		}
+		if c.high > high {
+			high = c.high
+		}
+	}
+
+	span := float64(high - low)
+	if span == 0 {
+		span = 1
+	}
+	row := func(price uint64) int {
+		return int(math.Round(float64(price-low) / span * float64(chartHeight-1)))
+	}
+
+	for r := chartHeight - 1; r >= 0; r-- {
+		label := ""
+		if r%4 == 0 || r == chartHeight-1 {
+			label = fmt.Sprintf("%f", (float64(low)+span*float64(r)/float64(chartHeight-1))/priceScale)
+		}
+
+		var line strings.Builder
+		for _, c := range cs {
+			bottom, top := row(c.open), row(c.close)
+			body := byte('#')
+			if c.close < c.open {
+				bottom, top = top, bottom
+				body = 'X'
+			}
+
+			switch {
+			case c.trades == 0:
+				line.WriteByte(' ')
+			case r >= bottom && r <= top:
+				line.WriteByte(body)
+			case r >= row(c.low) && r <= row(c.high):
+				line.WriteByte('|')
+			default:
+				line.WriteByte(' ')
+			}
+		}
+
+		fmt.Printf("%19s |%s\n", label, line.String())
+	}
+
+	first := time.Unix(cs[0].start, 0).Format("2006-01-02 15:04")
+	last := time.Unix(cs[len(cs)-1].start, 0).Format("2006-01-02 15:04")
+	fmt.Printf("%19s +%s\n", fmt.Sprintf("(%s)", symbols[1]), strings.Repeat("-", len(cs)))
+	fmt.Printf("%19s  %s .. %s\n", "", first, last)
+}
+
+// exportCandles writes candles as CSV.
+func exportCandles(cs []candle, symbols []string, file string) bool {
+	f, err := os.Create(file)
+	if err != nil {
+		fmt.Printf("cannot write %s: %s\n", file, err)
+		return false
+	}
+	defer f.Close()
+
+	scale := math.Pow10(tokens[symbols[0]].decimals)
+	price := func(p uint64) string { return strconv.FormatFloat(float64(p)/priceScale, 'f', -1, 64) }
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"time", "open", "high", "low", "close", "volume", "trades"})
+	for _, c := range cs {
+		w.Write([]string{time.Unix(c.start, 0).UTC().Format(time.RFC3339), price(c.open), price(c.high), price(c.low), price(c.close),
+			strconv.FormatFloat(float64(c.volume)/scale, 'f', -1, 64), strconv.Itoa(c.trades)})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		fmt.Printf("cannot write %s: %s\n", file, err)
+		return false
+	}
+	return true
+}
+
+func tradeChart(words []string) {
+	if len(words) != 2 && len(words) != 4 {
+		fmt.Println("chart requires 2 or 4 arguments")
+		tradeHelp()
+		return
+	}
+
+	if len(words) == 4 && words[2] != "export" {
+		fmt.Printf("unknown option '%s', expected export <file>\n", words[2])
+		return
+	}
+
+	interval, ok := parseInterval(words[1])
+	if !ok {
+		fmt.Printf("invalid interval '%s', use e.g. 1m, 1h or 1d\n", words[1])
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+
+	if len(pair.hist) == 0 {
+		fmt.Printf("pair '%s' has no trades yet\n", words[0])
+		return
+	}
+
+	// the window ends now, whenever the last trade was
+	now := time.Now().Unix()
+	step := int64(interval.Seconds())
+
+	if len(words) == 4 {
+		first := int64(pair.hist[0].timestamp)
+		for _, h := range pair.hist {
+			if int64(h.timestamp) < first {
+				first = int64(h.timestamp)
+			}
+		}
+
+		from := now - (exportWidth-1)*step
+		if first > from {
+			from = first
+		}
+
+		cs := candles(pair.hist, interval, from, now)
+		if exportCandles(cs, symbols, words[3]) {
+			fmt.Printf("%d candles written to %s\n", len(cs), words[3])
+		}
+		return
+	}
+
+	cs := candles(pair.hist, interval, now-(chartWidth-1)*step, now)
+	if len(cs) == 0 {
+		fmt.Printf("pair '%s' has no trades yet\n", words[0])
+		return
+	}
+
+	printChart(cs, symbols)
+
+	var volume uint64
+	for _, c := range cs {
+		volume += c.volume
+	}
+	first, last := cs[0], cs[len(cs)-1]
+
+	fmt.Println()
+	fmt.Printf("Last %f %s, change %+.2f%%, volume %f %s\n", float64(last.close)/priceScale, symbols[1],
+		(float64(last.close)/float64(first.open)-1.0)*100.0,
+		float64(volume)/math.Pow10(tokens[symbols[0]].decimals), symbols[0])
+}
@@ -56,6 +56,29 @@ func multDiv(a uint64, b uint64, c uint64) (uint64) {
 	return C.Uint64()
 }
 
+// parseAmount converts a user amount to atomic units, "max" selects all of max.
+func parseAmount(str string, decimals int, max uint64) (uint64, bool) {
+	var amt uint64
+
+	if strings.ToLower(str) == "max" {
+		amt = max
+	} else {
+		var err error
+		amt, err = d.DeroStringToAmount(str, decimals)
+		if err != nil {
+			fmt.Printf("cannot parse amount '%s'\n", str)
+			return 0, false
+		}
+	}
+
+	if amt == 0 {
+		fmt.Println("amount must be > 0.0")
+		return 0, false
+	}
+
+	return amt, true
+}
+
 func main() {
 	walletOpts()
 
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Condition is a client side stop or take profit order. It fires once the
+// last trade crosses Trigger from the side it was created on.
+type Condition struct {
+	Id      uint64 `json:"id"`
+	Kind    string `json:"kind"`
+	Pair    string `json:"pair"`
+	Side    string `json:"side"`
+	Amount  uint64 `json:"amount"`
+	Trigger uint64 `json:"trigger"`
+	Limit   uint64 `json:"limit"` // 0 places a marketable order
+	Above   bool   `json:"above"`
+	Created int64  `json:"created"`
+}
+
+const conditionStore = "conditions"
+
+var conditions_mutex sync.Mutex
+
+// lastPrice is the price of the latest trade, or the mid of the book when
+// the pair has not traded yet.
+func lastPrice(pair TradePair) (uint64, bool) {
+	var last Hist
+	for _, h := range pair.hist {
+		if h.timestamp >= last.timestamp {
+			last = h
+		}
+	}
+	if last.timestamp > 0 {
+		return last.v2, true
+	}
+
+	bids := bookLevels(pair, "buy")
+	asks := bookLevels(pair, "sell")
+	if len(bids) == 0 || len(asks) == 0 {
+		return 0, false
+	}
+
+	return (bids[0].price + asks[0].price) / 2, true
+}
+
+// marketablePrice is the limit that fills amt on side at once, within the
+// protection band.
+func marketablePrice(pair TradePair, side string, amt uint64) (uint64, bool) {
+	book := "sell"
+	if side == "sell" {
+		book = "buy"
+	}
+
+	fills, _ := bookFills(pair, book, amt, 0)
+	if len(fills) == 0 {
+		return 0, false
+	}
+
+	return protectPrice(fills[len(fills)-1].price, side), true
+}
+
+func tradeCondition(words []string, kind string) {
+	if len(words) != 3 && len(words) != 4 {
+		fmt.Printf("%s requires 3 or 4 arguments\n", kind)
+		tradeHelp()
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+	tokenA := tokens[symbols[0]]
+
+	amt_float, err := strconv.ParseFloat(words[1], 64)
+	if err != nil {
+		fmt.Printf("cannot parse amount '%s'\n", words[1])
+		return
+	}
+
+	trigger_float, err := strconv.ParseFloat(words[2], 64)
+	if err != nil {
+		fmt.Printf("cannot parse amount '%s'\n", words[2])
+		return
+	}
+
+	limit_float := 0.0
+	if len(words) == 4 {
+		limit_float, err = strconv.ParseFloat(words[3], 64)
+		if err != nil || limit_float <= 0.0 {
+			fmt.Printf("cannot parse amount '%s'\n", words[3])
+			return
+		}
+	}
+
+	if amt_float <= 0.0 || trigger_float <= 0.0 {
+		fmt.Println("amounts must be > 0.0")
+		return
+	}
+
+	last, ok := lastPrice(pair)
+	if !ok {
+		fmt.Printf("pair '%s' has no price yet\n", words[0])
+		return
+	}
+
+	var c Condition
+	c.Kind = kind
+	c.Pair = words[0]
+	c.Amount = uint64(amt_float * math.Pow10(tokenA.decimals))
+	c.Trigger = uint64(trigger_float * priceScale)
+	c.Limit = uint64(limit_float * priceScale)
+	c.Above = c.Trigger > last
+	c.Created = time.Now().Unix()
+
+	// a stop follows the price through the trigger, a take profit fades it
+	if (kind == "stop") == c.Above {
+		c.Side = "buy"
+	} else {
+		c.Side = "sell"
+	}
+
+	if c.Trigger == last {
+		fmt.Println("trigger equals the last price")
+		return
+	}
+
+	limit := "market"
+	if c.Limit > 0 {
+		limit = fmt.Sprintf("limit %f %s", limit_float, symbols[1])
+	}
+
+	fmt.Printf("Last price %f %s\n", float64(last)/priceScale, symbols[1])
+	fmt.Printf("%s: %s %f %s at %s once the price crosses %f %s\n", kind, c.Side, amt_float, symbols[0], limit, trigger_float, symbols[1])
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	conditions_mutex.Lock()
+	defer conditions_mutex.Unlock()
+
+	var list []Condition
+	if !loadStore(conditionStore, &list) {
+		return
+	}
+
+	for _, o := range list {
+		if o.Id >= c.Id {
+			c.Id = o.Id + 1
+		}
+	}
+	if c.Id == 0 {
+		c.Id = 1
+	}
+
+	list = append(list, c)
+	if saveStore(conditionStore, list) {
+		fmt.Printf("Condition %d saved\n", c.Id)
+	}
+}
+
+func tradeConditions(words []string) {
+	conditions_mutex.Lock()
+	defer conditions_mutex.Unlock()
+
+	var list []Condition
+	if !loadStore(conditionStore, &list) {
+		return
+	}
+
+	if len(words) == 2 && words[0] == "cancel" {
+		id, err := strconv.Atoi(words[1])
+		if err != nil {
+			fmt.Println("invalid condition number")
+			return
+		}
+
+		for i, c := range list {
+			if c.Id == uint64(id) {
+				list = append(list[:i], list[i+1:]...)
+				if saveStore(conditionStore, list) {
+					fmt.Printf("Condition %d cancelled\n", id)
+				}
+				return
+			}
+		}
+
+		fmt.Printf("condition %d not found\n", id)
+		return
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+
+	fmt.Printf("%5s %-10s %-20s %4s %19s %19s %19s\n\n", "ID", "KIND", "PAIR", "SIDE", "AMOUNT", "TRIGGER", "LIMIT")
+	for _, c := range list {
+		symbols := strings.Split(c.Pair, ":")
+		fmt.Printf("%5d %-10s %-20s %4s %19f %19f %19f\n", c.Id, c.Kind, c.Pair, c.Side,
+			float64(c.Amount)/math.Pow10(tokens[symbols[0]].decimals),
+			float64(c.Trigger)/priceScale, float64(c.Limit)/priceScale)
+	}
+}
+
+// checkConditions submits the orders of every condition whose trigger was crossed.
+func checkConditions(fetch func() map[string]TradePair) {
+	conditions_mutex.Lock()
+	defer conditions_mutex.Unlock()
+
+	var list []Condition
+	if !loadStore(conditionStore, &list) || len(list) == 0 {
+		return
+	}
+
+	books := fetch()
+
+	var keep []Condition
+	for _, c := range list {
+		pair := books[c.Pair]
+		last, ok := lastPrice(pair)
+		if !ok || (c.Above && last < c.Trigger) || (!c.Above && last > c.Trigger) {
+			keep = append(keep, c)
+			continue
+		}
+
+		price := c.Limit
+		if price == 0 {
+			price, ok = marketablePrice(pair, c.Side, c.Amount)
+			if !ok {
+				notify("%s %d on %s triggered but the book is empty, retrying\n", c.Kind, c.Id, c.Pair)
+				keep = append(keep, c)
+				continue
+			}
+		}
+
+		transfers, args := sellOrder(c.Pair, c.Amount, price)
+		if c.Side == "buy" {
+			transfers, args = buyOrder(c.Pair, c.Amount, price)
+		}
+
+		txid, b := sendOrder(pair.contract, transfers, args)
+		if !b {
+			notify("%s %d on %s triggered but the order failed, retrying\n", c.Kind, c.Id, c.Pair)
+			keep = append(keep, c)
+			continue
+		}
+
+		notify("%s %d on %s triggered at %f, %s order placed: txid = %s\n", c.Kind, c.Id, c.Pair,
+			float64(last)/priceScale, c.Side, txid)
+	}
+
+	saveStore(conditionStore, keep)
+}
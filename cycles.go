@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	d "github.com/deroholic/derogo"
+)
+
+// cycle is a round trip through the pools that ends with more of its first
+// symbol than it started with.
+type cycle struct {
+	path []string
+	hops []hop
+	in   uint64
+	out  uint64
+}
+
+// edgeCost is the negative log of the rate of a tokenGraph edge after the
+// pool fee, a cycle whose costs sum below zero returns more than it takes.
+func edgeCost(v int, w int, c int64) (float64, bool) {
+	key, found := findPair(tokenList[v], tokenList[w])
+	if !found {
+		return 0, false
+	}
+
+	rate := float64(c) / math.Pow(10, 7)
+	if rate <= 0 {
+		return 0, false
+	}
+
+	return -math.Log(rate * float64(feeDenom-pairs[key].fee) / feeDenom), true
+}
+
+// negativeCycles walks tokenGraph for cycles of 3 to maxHops pools with a
+// negative total cost. Each cycle is found once, starting at its lowest token.
+func negativeCycles() (paths [][]string) {
+	var walk func(start int, path []int, cost float64)
+	walk = func(start int, path []int, cost float64) {
+		v := path[len(path)-1]
+		tokenGraph.Visit(v, func(w int, c int64) bool {
+			edge, ok := edgeCost(v, w, c)
+			if !ok {
+				return false
+			}
+
+			if w == start {
+				if len(path) >= 3 && cost+edge < 0 {
+					var symbols []string
+					for _, n := range append(path, start) {
+						symbols = append(symbols, tokenList[n])
+					}
+					paths = append(paths, symbols)
+				}
+				return false
+			}
+
+			if w < start || len(path) >= maxHops {
+				return false
+			}
+			for _, n := range path {
+				if n == w {
+					return false
+				}
+			}
+
+			walk(start, append(path[:len(path):len(path)], w), cost+edge)
+			return false
+		})
+	}
+
+	for start := 0; start < tokenGraph.Order(); start++ {
+		walk(start, []int{start}, 0)
+	}
+
+	return
+}
+
+// cycleProfit is what a round trip of amt returns above amt.
+func cycleProfit(path []string, amt uint64) (hops []hop, profit int64) {
+	hops, ok := simulateRoute(path, amt)
+	if !ok || len(hops) != len(path)-1 {
+		return nil, math.MinInt64
+	}
+
+	return hops, int64(hops[len(hops)-1].out) - int64(amt)
+}
+
+// bestCycle simulates the size with the most profit against real reserves.
+// Fees and price impact make the profit concave in the size.
+func bestCycle(path []string) (best cycle, found bool) {
+	key, _ := findPair(path[0], path[1])
+	_, hi := poolReserves(key, path[1])
+	lo := uint64(1)
+
+	for hi-lo > 2 {
+		m1 := lo + (hi-lo)/3
+		m2 := hi - (hi-lo)/3
+		_, p1 := cycleProfit(path, m1)
+		_, p2 := cycleProfit(path, m2)
+		if p1 < p2 {
+			lo = m1 + 1
+		} else {
+			hi = m2
+		}
+	}
+
+	for amt := lo; amt <= hi; amt++ {
+		hops, profit := cycleProfit(path, amt)
+		if profit > 0 && (!found || profit > int64(best.out)-int64(best.in)) {
+			best, found = cycle{path, hops, amt, hops[len(hops)-1].out}, true
+		}
+	}
+
+	return
+}
+
+// cycleValue is the profit of c in USDT.
+func cycleValue(c cycle) float64 {
+	ratio, _ := conversion(c.path[0], "DUSDT")
+	return float64(c.out-c.in) / math.Pow10(tokens[c.path[0]].decimals) * ratio
+}
+
+func findCycles() (cycles []cycle) {
+	getTokens()
+	getPairs()
+
+	for _, path := range negativeCycles() {
+		if c, found := bestCycle(path); found {
+			cycles = append(cycles, c)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycleValue(cycles[i]) > cycleValue(cycles[j]) })
+	return
+}
+
+func arbCycles(words []string) {
+	if len(words) != 0 && (len(words) != 2 || words[0] != "execute") {
+		fmt.Println("arb cycles requires 0 or 2 arguments")
+		printHelp()
+		return
+	}
+
+	cycles := findCycles()
+	if len(cycles) == 0 {
+		fmt.Printf("No profitable cycles of up to %d pools\n", maxHops)
+		return
+	}
+
+	if len(words) == 2 {
+		n, err := strconv.Atoi(words[1])
+		if err != nil || n < 1 || n > len(cycles) {
+			fmt.Printf("invalid cycle '%s', must be 1 to %d\n", words[1], len(cycles))
+			return
+		}
+		executeCycle(cycles[n-1])
+		return
+	}
+
+	fmt.Printf("%3s %-40s %19s %19s %12s\n\n", "", "CYCLE", "SIZE", "PROFIT", "PROFIT USDT")
+	for i, c := range cycles {
+		dec := tokens[c.path[0]].decimals
+		fmt.Printf("%2d) %-40s %19f %19f %12.2f\n", i+1, strings.Join(c.path, " => "),
+			d.DeroFormatMoneyPrecision(c.in, dec), d.DeroFormatMoneyPrecision(c.out-c.in, dec), cycleValue(c))
+	}
+	fmt.Println()
+	fmt.Println("Profits are after pool fees, run 'arb cycles execute <n>' to trade one")
+}
+
+// executeCycle runs a cycle through the swap confirmation flow, sized down
+// to the wallet's balance when needed.
+func executeCycle(c cycle) {
+	from := c.path[0]
+	dec := tokens[from].decimals
+
+	if bal := d.DeroGetSCBal(tokens[from].contract); bal < c.in {
+		fmt.Printf("Balance %f %s is below the best size %f %s, using the balance\n",
+			d.DeroFormatMoneyPrecision(bal, dec), from, d.DeroFormatMoneyPrecision(c.in, dec), from)
+
+		hops, profit := cycleProfit(c.path, bal)
+		if profit <= 0 {
+			fmt.Println("cycle is not profitable at that size")
+			return
+		}
+		c = cycle{c.path, hops, bal, hops[len(hops)-1].out}
+	}
+
+	fmt.Printf("Swapping %f %s for %f %s fees included via %s\n",
+		d.DeroFormatMoneyPrecision(c.in, dec), from, d.DeroFormatMoneyPrecision(c.out, dec), from, strings.Join(c.path, " => "))
+	printHops(c.hops)
+
+	minimum := minReceived(c.out, maxSlippage)
+	fmt.Printf("Minimum received %f %s (max slippage %.2f%%)\n", d.DeroFormatMoneyPrecision(minimum, dec), from, maxSlippage)
+	if minimum <= c.in {
+		fmt.Println("Warning: at the minimum the cycle loses, consider a lower slippage")
+	}
+
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	getPairs()
+
+	hops, ok := simulateRoute(c.path, c.in)
+	if !ok || hops[len(hops)-1].out < minimum {
+		fmt.Println("Reserves moved, the cycle now returns below the minimum, refusing.")
+		return
+	}
+
+	if executeRoute(hops, maxSlippage) {
+		fmt.Println("Cycle submitted.")
+	}
+}
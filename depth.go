@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// depth chart size in levels per side and bar width
+const (
+	depthLevels = 15
+	depthWidth  = 50
+)
+
+var depthBands = []float64{1, 2, 5}
+
+// depthWithin sums the amount of levels priced within limit, levels are best price first.
+func depthWithin(levels []ordSum, side string, limit float64) (amount uint64) {
+	for _, l := range levels {
+		if (side == "buy" && float64(l.price) < limit) || (side == "sell" && float64(l.price) > limit) {
+			break
+		}
+		amount += l.amount
+	}
+	return
+}
+
+// printBookMetrics shows best bid and ask, spread, mid price and the depth
+// around mid.
+func printBookMetrics(pair TradePair, symbols []string) {
+	bids := bookLevels(pair, "buy")
+	asks := bookLevels(pair, "sell")
+
+	if len(bids) == 0 || len(asks) == 0 {
+		fmt.Println("Book is one sided, no spread or mid price")
+		return
+	}
+
+	scale := math.Pow10(tokens[symbols[0]].decimals)
+	bid, ask := bids[0].price, asks[0].price
+	mid := (float64(bid) + float64(ask)) / 2.0
+	spread := float64(ask) - float64(bid)
+
+	fmt.Printf("Best bid %f, best ask %f %s\n", float64(bid)/priceScale, float64(ask)/priceScale, symbols[1])
+	fmt.Printf("Spread %f %s (%.1f bps), mid %f %s\n", spread/priceScale, symbols[1], spread/mid*10000.0, mid/priceScale, symbols[1])
+	fmt.Println()
+	fmt.Printf("%8s %19s %19s\n", "DEPTH", fmt.Sprintf("BIDS (%s)", symbols[0]), fmt.Sprintf("ASKS (%s)", symbols[0]))
+	for _, band := range depthBands {
+		fmt.Printf("%7.0f%% %19f %19f\n", band,
+			float64(depthWithin(bids, "buy", mid*(1.0-band/100.0)))/scale,
+			float64(depthWithin(asks, "sell", mid*(1.0+band/100.0)))/scale)
+	}
+}
+
+// tradeDepth draws the cumulative depth of both sides as horizontal bars,
+// asks above and bids below the spread.
+func tradeDepth(words []string) {
+	if len(words) != 1 {
+		fmt.Println("depth requires 1 arguments")
+		tradeHelp()
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+	scale := math.Pow10(tokens[symbols[0]].decimals)
+
+	bids := bookLevels(pair, "buy")
+	asks := bookLevels(pair, "sell")
+	if len(bids) > depthLevels {
+		bids = bids[:depthLevels]
+	}
+	if len(asks) > depthLevels {
+		asks = asks[:depthLevels]
+	}
+
+	if len(bids) == 0 && len(asks) == 0 {
+		fmt.Printf("pair '%s' has no orders\n", words[0])
+		return
+	}
+
+	var most uint64
+	if len(bids) > 0 {
+		most = bids[len(bids)-1].total
+	}
+	if len(asks) > 0 && asks[len(asks)-1].total > most {
+		most = asks[len(asks)-1].total
+	}
+
+	bar := func(l ordSum) string {
+		return strings.Repeat("#", int(math.Ceil(float64(l.total)/float64(most)*depthWidth)))
+	}
+
+	fmt.Printf("%-4s %19s %19s\n\n", "TYPE", fmt.Sprintf("PRICE (%s)", symbols[1]), fmt.Sprintf("TOTAL (%s)", symbols[0]))
+	for i := len(asks) - 1; i >= 0; i-- {
+		fmt.Printf("SELL %19f %19f %s\n", float64(asks[i].price)/priceScale, float64(asks[i].total)/scale, bar(asks[i]))
+	}
+	fmt.Println()
+	for _, l := range bids {
+		fmt.Printf("BUY  %19f %19f %s\n", float64(l.price)/priceScale, float64(l.total)/scale, bar(l))
+	}
+	fmt.Println()
+
+	printBookMetrics(pair, symbols)
+}
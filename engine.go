@@ -0,0 +1,97 @@
+package main
+
+import (
+	"time"
+)
+
+// how often the background order tasks look at the books
+const engineInterval = 15 * time.Second
+
+// an order the engine placed that is not on the book gets placeGrace to show
+// up or to show as filled in the trade history, after placeTimeout its
+// transaction is taken to have failed
+const placeGrace = 2 * time.Minute
+const placeTimeout = 10 * time.Minute
+
+// what became of an order the engine placed
+const (
+	placePending = iota
+	placeOpen
+	placeFilled
+	placeFailed
+	placeCancelled // gone without a confirmed fill, by cldex or elsewhere
+)
+
+// placement looks up an order the engine placed, order is 0 until it was
+// matched on the book. A fill is only taken as confirmed when the order
+// recorder saw it or the trade history has it, filled is the amount
+// confirmed so far.
+func placement(key string, pair TradePair, mine map[uint64]Order, order uint64, side string, amt uint64, price uint64, placed int64) (state int, filled uint64) {
+	if order > 0 {
+		if o, found := mine[order]; found {
+			return placeOpen, o.o1 - o.v1
+		}
+
+		filled = orderFilled(key, order)
+		if wasCancelled(key, order) || filled < amt {
+			return placeCancelled, filled
+		}
+		return placeFilled, amt
+	}
+
+	// never seen on the book, it filled on placement or is not mined yet
+	age := time.Now().Unix() - placed
+	switch {
+	case age <= int64(placeGrace.Seconds()):
+		return placePending, 0
+	case histCrossed(pair.hist, side, price, placed) >= amt:
+		return placeFilled, amt
+	case age <= int64(placeTimeout.Seconds()):
+		return placePending, 0
+	}
+
+	return placeFailed, 0
+}
+
+// cancelPlaced cancels an order of the engine once, later calls wait for
+// the cancel to show.
+func cancelPlaced(key string, pair TradePair, order uint64) {
+	if wasCancelled(key, order) {
+		return
+	}
+
+	transfers, args := cancelOrder(order)
+	if _, b := sendOrder(pair.contract, transfers, args); b {
+		markCancelled(key, order)
+	}
+}
+
+// orderEngine runs the client side order tasks alongside update_prompt. The
+// books are only read when a task asks for them.
+func orderEngine() {
+	var sampled time.Time
+
+	for {
+		time.Sleep(engineInterval)
+
+		if time.Since(sampled) >= poolSampleInterval {
+			samplePools()
+			sampled = time.Now()
+		}
+
+		// every read of the books is also an order snapshot, so the tasks
+		// see the fills of their orders confirmed
+		var books map[string]TradePair
+		fetch := func() map[string]TradePair {
+			if books == nil {
+				books = fetchTradePairs()
+				recordFills(books)
+			}
+			return books
+		}
+
+		checkConditions(fetch)
+		checkParents(fetch)
+		checkGrids(fetch)
+	}
+}
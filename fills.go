@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrderSnap is the state of one of the wallet's orders when last seen.
+type OrderSnap struct {
+	Side  string `json:"side"`
+	Price uint64 `json:"price"`
+	O1    uint64 `json:"o1"`
+	V1    uint64 `json:"v1"`
+}
+
+// Fill is a fill of one of the wallet's orders inferred from its unfilled amount.
+type Fill struct {
+	Time   int64  `json:"time"`
+	Order  uint64 `json:"order"`
+	Side   string `json:"side"`
+	Price  uint64 `json:"price"`
+	Amount uint64 `json:"amount"`
+}
+
+// FillBook holds the order snapshots fills are inferred from with the time
+// each was taken, the orders cldex cancelled and when, and the fills found so
+// far, all keyed by pair.
+type FillBook struct {
+	Orders  map[string]map[uint64]OrderSnap `json:"orders"`
+	Snapped map[string]int64                `json:"snapped"`
+	Cancels map[string]map[uint64]int64     `json:"cancels"`
+	Fills   map[string][]Fill               `json:"fills"`
+}
+
+const fillStore = "fills"
+
+// cancellations are kept this long, so tasks that look after their orders
+// less often than the snapshots are taken still see them
+const cancelKeep = 7 * 24 * time.Hour
+
+// trade history timestamps come from blocks, which can trail the snapshot
+// that still showed the order
+const histSlack = 60
+
+var fills_mutex sync.Mutex
+
+func loadFills() (fb FillBook, ok bool) {
+	if !loadStore(fillStore, &fb) {
+		return
+	}
+
+	if fb.Orders == nil {
+		fb.Orders = make(map[string]map[uint64]OrderSnap)
+	}
+	if fb.Snapped == nil {
+		fb.Snapped = make(map[string]int64)
+	}
+	if fb.Cancels == nil {
+		fb.Cancels = make(map[string]map[uint64]int64)
+	}
+	if fb.Fills == nil {
+		fb.Fills = make(map[string][]Fill)
+	}
+
+	return fb, true
+}
+
+// markCancelled records orders cancelled by cldex, so their disappearance
+// is not taken for a fill.
+func markCancelled(key string, orders ...uint64) {
+	fills_mutex.Lock()
+	defer fills_mutex.Unlock()
+
+	fb, ok := loadFills()
+	if !ok {
+		return
+	}
+
+	if fb.Cancels[key] == nil {
+		fb.Cancels[key] = make(map[uint64]int64)
+	}
+	for _, k := range orders {
+		fb.Cancels[key][k] = time.Now().Unix()
+	}
+	saveStore(fillStore, fb)
+}
+
+// wasCancelled reports whether cldex cancelled order on pair key.
+func wasCancelled(key string, order uint64) bool {
+	fills_mutex.Lock()
+	defer fills_mutex.Unlock()
+
+	fb, ok := loadFills()
+	if !ok {
+		return false
+	}
+
+	_, found := fb.Cancels[key][order]
+	return found
+}
+
+// orderFilled is the amount of an order recorded as filled.
+func orderFilled(key string, order uint64) (filled uint64) {
+	fills_mutex.Lock()
+	defer fills_mutex.Unlock()
+
+	fb, ok := loadFills()
+	if !ok {
+		return
+	}
+
+	for _, f := range fb.Fills[key] {
+		if f.Order == order {
+			filled += f.Amount
+		}
+	}
+	return
+}
+
+// histVolume is the amount traded at price since a unix time.
+func histVolume(hist []Hist, price uint64, since int64) (volume uint64) {
+	for _, h := range hist {
+		if h.v2 == price && int64(h.timestamp) >= since-histSlack {
+			volume += h.v1
+		}
+	}
+	return
+}
+
+// histCrossed is the amount traded since a unix time at prices an order of
+// side with limit would have taken.
+func histCrossed(hist []Hist, side string, limit uint64, since int64) (volume uint64) {
+	for _, h := range hist {
+		if int64(h.timestamp) < since-histSlack {
+			continue
+		}
+		if (side == "buy" && h.v2 <= limit) || (side == "sell" && h.v2 >= limit) {
+			volume += h.v1
+		}
+	}
+	return
+}
+
+// histTime is the time of the latest trade at price since a unix time, or
+// now when the history has none.
+func histTime(hist []Hist, price uint64, since int64, now int64) int64 {
+	var last int64
+	for _, h := range hist {
+		if h.v2 == price && int64(h.timestamp) >= since-histSlack && int64(h.timestamp) > last {
+			last = int64(h.timestamp)
+		}
+	}
+	if last == 0 {
+		return now
+	}
+	return last
+}
+
+// snapOrders is the wallet's open orders of pair.
+func snapOrders(pair TradePair) map[uint64]OrderSnap {
+	snap := make(map[uint64]OrderSnap)
+	for k, o := range myOrders(pair) {
+		snap[k] = OrderSnap{o.t, pair.prices[o.n], o.o1, o.v1}
+	}
+	return snap
+}
+
+// inferFills compares the wallet's orders with the previous snapshot, taken
+// at since. A drop of the unfilled amount is a fill, a new order that is
+// already partly filled matched on placement. An order that is gone only
+// filled when the trade history since the snapshot has the volume at its
+// price, otherwise it is returned as removed. Orders cldex cancelled are
+// neither.
+func inferFills(prev map[uint64]OrderSnap, cur map[uint64]OrderSnap, cancels map[uint64]int64, hist []Hist, since int64) (fills []Fill, removed []uint64) {
+	now := time.Now().Unix()
+
+	var gone []uint64
+	for k, p := range prev {
+		c, open := cur[k]
+		switch {
+		case open && c.V1 < p.V1:
+			fills = append(fills, Fill{now, k, p.Side, p.Price, p.V1 - c.V1})
+		case !open && p.V1 > 0:
+			if _, cancelled := cancels[k]; !cancelled {
+				gone = append(gone, k)
+			}
+		}
+	}
+
+	for k, c := range cur {
+		if _, seen := prev[k]; !seen && c.V1 < c.O1 {
+			fills = append(fills, Fill{now, k, c.Side, c.Price, c.O1 - c.V1})
+		}
+	}
+
+	// the fills seen on the book took their share of the traded volume
+	traded := make(map[uint64]uint64)
+	for _, f := range fills {
+		traded[f.Price] += f.Amount
+	}
+
+	sort.Slice(gone, func(i, j int) bool { return gone[i] < gone[j] })
+	for _, k := range gone {
+		p := prev[k]
+		if since > 0 && histVolume(hist, p.Price, since) >= traded[p.Price]+p.V1 {
+			traded[p.Price] += p.V1
+			fills = append(fills, Fill{now, k, p.Side, p.Price, p.V1})
+		} else {
+			removed = append(removed, k)
+		}
+	}
+
+	// fills are dated by the trade that made them, not when they were seen
+	for i := range fills {
+		if since > 0 {
+			fills[i].Time = histTime(hist, fills[i].Price, since, now)
+		}
+	}
+
+	sort.Slice(fills, func(i, j int) bool { return fills[i].Order < fills[j].Order })
+	return
+}
+
+// orderEvent is a change to one of the wallet's orders found by recordFills.
+// An order that left the book without a confirmed fill is unknown, it was
+// cancelled outside cldex or its fill is not in the trade history.
+type orderEvent struct {
+	pair      string
+	fill      Fill
+	filled    float64 // percent of the order filled so far
+	closed    bool
+	cancelled bool
+	unknown   bool
+}
+
+// percentFilled is how much of an order had filled when it was seen.
+func percentFilled(o OrderSnap) float64 {
+	if o.O1 == 0 {
+		return 0
+	}
+	return float64(o.O1-o.V1) / float64(o.O1) * 100.0
+}
+
+// recordFills snapshots the wallet's orders on every pair, stores the fills
+// since the last snapshot and queues what changed for the watcher.
+func recordFills(books map[string]TradePair) {
+	fills_mutex.Lock()
+	defer fills_mutex.Unlock()
+
+	fb, ok := loadFills()
+	if !ok {
+		return
+	}
+
+	var events []orderEvent
+	now := time.Now()
+	for key, pair := range books {
+		cur := snapOrders(pair)
+		if prev, found := fb.Orders[key]; found {
+			fills, removed := inferFills(prev, cur, fb.Cancels[key], pair.hist, fb.Snapped[key])
+			fb.Fills[key] = append(fb.Fills[key], fills...)
+
+			for _, f := range fills {
+				e := orderEvent{pair: key, fill: f, filled: 100.0, closed: true}
+				if c, open := cur[f.Order]; open {
+					e.filled = percentFilled(c)
+					e.closed = false
+				}
+				events = append(events, e)
+			}
+
+			for _, k := range removed {
+				p := prev[k]
+				events = append(events, orderEvent{pair: key, fill: Fill{Time: now.Unix(), Order: k, Side: p.Side, Price: p.Price},
+					filled: percentFilled(p), closed: true, unknown: true})
+			}
+
+			for k := range fb.Cancels[key] {
+				p, was := prev[k]
+				if _, open := cur[k]; was && !open {
+					events = append(events, orderEvent{pair: key, fill: Fill{Time: now.Unix(), Order: k, Side: p.Side, Price: p.Price},
+						filled: percentFilled(p), closed: true, cancelled: true})
+				}
+			}
+		}
+		fb.Orders[key] = cur
+		fb.Snapped[key] = now.Unix()
+
+		cutoff := now.Add(-cancelKeep).Unix()
+		for k, t := range fb.Cancels[key] {
+			if _, open := cur[k]; !open && t < cutoff {
+				delete(fb.Cancels[key], k)
+			}
+		}
+	}
+
+	saveStore(fillStore, fb)
+	queueEvents(events)
+}
+
+// fillPnl runs fills through an average cost position, returning the
+// position in the first symbol, its average entry price and the realized
+// P&L in the second symbol.
+func fillPnl(fills []Fill, dec1 int) (position float64, entry float64, realized float64) {
+	for _, f := range fills {
+		amt := float64(f.Amount) / math.Pow10(dec1)
+		price := float64(f.Price) / priceScale
+		if f.Side == "sell" {
+			amt = -amt
+		}
+
+		// closing part of the position realizes it against the entry price
+		if position != 0 && (position > 0) != (amt > 0) {
+			closed := math.Min(math.Abs(amt), math.Abs(position))
+			if position > 0 {
+				realized += closed * (price - entry)
+			} else {
+				realized += closed * (entry - price)
+			}
+		}
+
+		switch {
+		case position == 0 || (position > 0) == (amt > 0):
+			entry = (entry*math.Abs(position) + price*math.Abs(amt)) / (math.Abs(position) + math.Abs(amt))
+		case math.Abs(amt) > math.Abs(position):
+			entry = price
+		}
+		position += amt
+		if math.Abs(position) < 1/math.Pow10(dec1) {
+			position = 0
+		}
+	}
+
+	return
+}
+
+func tradeFills(words []string) {
+	if len(words) != 1 {
+		fmt.Println("fills requires 1 arguments")
+		tradeHelp()
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	recordFills(tradePairs)
+
+	fills_mutex.Lock()
+	fb, ok := loadFills()
+	fills_mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+	dec1 := tokens[symbols[0]].decimals
+
+	fills := fb.Fills[words[0]]
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time < fills[j].Time })
+
+	fmt.Printf("Fills:\n\n")
+	fmt.Printf("%-29s %5s %4s %19s %19s\n", "TIME", "ORDER", "TYPE", fmt.Sprintf("PRICE (%s)", symbols[1]), fmt.Sprintf("AMOUNT (%s)", symbols[0]))
+	for _, f := range fills {
+		fmt.Printf("%29s %5d %4s %19f %19f\n", time.Unix(f.Time, 0), f.Order, f.Side,
+			float64(f.Price)/priceScale, float64(f.Amount)/math.Pow10(dec1))
+	}
+
+	if len(fills) == 0 {
+		fmt.Println()
+		fmt.Println("No fills recorded yet, fills are found while cldex is running.")
+		return
+	}
+
+	position, entry, realized := fillPnl(fills, dec1)
+
+	fmt.Println()
+	fmt.Printf("Position %f %s", position, symbols[0])
+	if position != 0 {
+		fmt.Printf(", average entry %f %s", entry, symbols[1])
+	}
+	fmt.Println()
+	fmt.Printf("Realized P&L %f %s\n", realized, symbols[1])
+
+	if last, ok := lastPrice(pair); ok && position != 0 {
+		unrealized := position * (float64(last)/priceScale - entry)
+		fmt.Printf("Unrealized P&L %f %s at %f %s\n", unrealized, symbols[1], float64(last)/priceScale, symbols[1])
+	}
+	fmt.Println("P&L is before trading fees")
+}
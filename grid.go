@@ -0,0 +1,550 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	d "github.com/deroholic/derogo"
+)
+
+// GridOrder is one order of a grid ladder. Placed is 0 while it waits for
+// the order engine.
+type GridOrder struct {
+	Level   int    `json:"level"`
+	Side    string `json:"side"`
+	Order   uint64 `json:"order"` // 0 until seen on the book
+	After   uint64 `json:"after"` // highest order number when placed
+	Placed  int64  `json:"placed"`
+	Txid    string `json:"txid"`
+	Counter bool   `json:"counter"` // placed after a fill one level away
+}
+
+// Grid is a ladder of buy and sell orders between Low and High. Every fill
+// is answered with the opposite order one level away.
+type Grid struct {
+	Id      uint64      `json:"id"`
+	Pair    string      `json:"pair"`
+	Low     uint64      `json:"low"`
+	High    uint64      `json:"high"`
+	Levels  int         `json:"levels"`
+	Amount  uint64      `json:"amount"`
+	Orders  []GridOrder `json:"orders"`
+	Buys    uint64      `json:"buys"`
+	Sells   uint64      `json:"sells"`
+	Trips   uint64      `json:"trips"`
+	Profit  uint64      `json:"profit"` // second symbol, before fees
+	Fees    uint64      `json:"fees"`   // second symbol
+	Created int64       `json:"created"`
+	Stopped bool        `json:"stopped"`
+}
+
+const gridStore = "grids"
+
+var grids_mutex sync.Mutex
+
+func (g *Grid) price(level int) uint64 {
+	return g.Low + (g.High-g.Low)*uint64(level)/uint64(g.Levels-1)
+}
+
+func (g *Grid) status() string {
+	if g.Stopped {
+		if len(g.Orders) > 0 {
+			return "stopping"
+		}
+		return "stopped"
+	}
+	return "running"
+}
+
+// openOrders is the grid's orders on the book and the number still confirming.
+func (g *Grid) openOrders() (open []uint64, pending int) {
+	for _, o := range g.Orders {
+		if o.Order > 0 {
+			open = append(open, o.Order)
+		} else if o.Placed > 0 {
+			pending++
+		}
+	}
+	return
+}
+
+// loadGrid reads one grid without holding the lock afterwards.
+func loadGrid(id uint64) (g Grid, found bool) {
+	grids_mutex.Lock()
+	defer grids_mutex.Unlock()
+
+	var list []Grid
+	if !loadStore(gridStore, &list) {
+		return
+	}
+
+	for _, o := range list {
+		if o.Id == id {
+			return o, true
+		}
+	}
+	return
+}
+
+func tradeGrid(words []string) {
+	if len(words) > 0 {
+		switch words[0] {
+		case "status":
+			gridStatus()
+			return
+		case "stop":
+			gridStop(words[1:])
+			return
+		case "profit":
+			gridProfit()
+			return
+		}
+	}
+
+	if len(words) != 5 {
+		fmt.Println("grid requires 5 arguments")
+		tradeHelp()
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+	tokenA := tokens[symbols[0]]
+	tokenB := tokens[symbols[1]]
+
+	low_float, err := strconv.ParseFloat(words[1], 64)
+	if err != nil {
+		fmt.Printf("cannot parse amount '%s'\n", words[1])
+		return
+	}
+
+	high_float, err := strconv.ParseFloat(words[2], 64)
+	if err != nil {
+		fmt.Printf("cannot parse amount '%s'\n", words[2])
+		return
+	}
+
+	levels, err := strconv.Atoi(words[3])
+	if err != nil || levels < 2 {
+		fmt.Printf("invalid number of levels '%s', at least 2 are needed\n", words[3])
+		return
+	}
+
+	amt_float, err := strconv.ParseFloat(words[4], 64)
+	if err != nil {
+		fmt.Printf("cannot parse amount '%s'\n", words[4])
+		return
+	}
+
+	if low_float <= 0.0 || amt_float <= 0.0 {
+		fmt.Println("amounts must be > 0.0")
+		return
+	}
+
+	if high_float <= low_float {
+		fmt.Println("high must be above low")
+		return
+	}
+
+	var g Grid
+	g.Pair = words[0]
+	g.Low = uint64(low_float * priceScale)
+	g.High = uint64(high_float * priceScale)
+	g.Levels = levels
+	g.Amount = uint64(amt_float * math.Pow10(tokenA.decimals))
+	g.Created = time.Now().Unix()
+
+	if g.price(1) == g.price(0) || g.Amount == 0 {
+		fmt.Println("grid is too fine for the price and amount precision")
+		return
+	}
+
+	last, ok := lastPrice(pair)
+	if !ok {
+		fmt.Printf("pair '%s' has no price yet\n", words[0])
+		return
+	}
+
+	// buy below the last price and sell above it, the level at the price stays empty
+	var cost, sell uint64
+	for level := 0; level < levels; level++ {
+		price := g.price(level)
+		switch {
+		case price < last:
+			g.Orders = append(g.Orders, GridOrder{Level: level, Side: "buy"})
+			cost += orderCost(g.Amount, price, tokenA.decimals, tokenB.decimals) + 1
+		case price > last:
+			g.Orders = append(g.Orders, GridOrder{Level: level, Side: "sell"})
+			sell += g.Amount
+		}
+	}
+
+	fmt.Printf("Last price %f %s\n\n", float64(last)/priceScale, symbols[1])
+	fmt.Printf("%5s %4s %19s %19s\n\n", "LEVEL", "SIDE", fmt.Sprintf("PRICE (%s)", symbols[1]), fmt.Sprintf("AMOUNT (%s)", symbols[0]))
+	for i := len(g.Orders) - 1; i >= 0; i-- {
+		o := g.Orders[i]
+		fmt.Printf("%5d %4s %19f %19f\n", o.Level, o.Side, float64(g.price(o.Level))/priceScale, amt_float)
+	}
+	fmt.Println()
+	fmt.Printf("Grid needs %f %s and %f %s, orders are placed one per engine tick\n",
+		float64(cost)/math.Pow10(tokenB.decimals), symbols[1], float64(sell)/math.Pow10(tokenA.decimals), symbols[0])
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	grids_mutex.Lock()
+	defer grids_mutex.Unlock()
+
+	var list []Grid
+	if !loadStore(gridStore, &list) {
+		return
+	}
+
+	for _, o := range list {
+		if o.Id >= g.Id {
+			g.Id = o.Id + 1
+		}
+	}
+	if g.Id == 0 {
+		g.Id = 1
+	}
+
+	list = append(list, g)
+	if saveStore(gridStore, list) {
+		fmt.Printf("Grid %d saved\n", g.Id)
+	}
+}
+
+func gridStatus() {
+	grids_mutex.Lock()
+	defer grids_mutex.Unlock()
+
+	var list []Grid
+	if !loadStore(gridStore, &list) {
+		return
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+
+	for _, g := range list {
+		symbols := strings.Split(g.Pair, ":")
+		fmt.Printf("Grid %d %s %s, %d levels from %f to %f %s, %f %s per level\n", g.Id, g.Pair, g.status(), g.Levels,
+			float64(g.Low)/priceScale, float64(g.High)/priceScale, symbols[1],
+			float64(g.Amount)/math.Pow10(tokens[symbols[0]].decimals), symbols[0])
+
+		if len(g.Orders) == 0 {
+			fmt.Println()
+			continue
+		}
+
+		sort.Slice(g.Orders, func(i, j int) bool { return g.Orders[i].Level > g.Orders[j].Level })
+
+		fmt.Printf("%5s %4s %19s %10s %-7s\n", "LEVEL", "SIDE", "PRICE", "ORDER", "STATE")
+		for _, o := range g.Orders {
+			state := "open"
+			if o.Placed == 0 {
+				state = "pending"
+			} else if o.Order == 0 {
+				state = "placed"
+			}
+			fmt.Printf("%5d %4s %19f %10d %-7s\n", o.Level, o.Side, float64(g.price(o.Level))/priceScale, o.Order, state)
+		}
+		fmt.Println()
+	}
+}
+
+func gridStop(words []string) {
+	if len(words) != 1 {
+		fmt.Println("grid stop requires 1 argument")
+		tradeHelp()
+		return
+	}
+
+	id, err := strconv.Atoi(words[0])
+	if err != nil {
+		fmt.Println("invalid grid number")
+		return
+	}
+
+	g, found := loadGrid(uint64(id))
+	if !found {
+		fmt.Printf("grid %d not found\n", id)
+		return
+	}
+
+	if g.Stopped {
+		fmt.Printf("grid %d is already %s\n", id, g.status())
+		return
+	}
+
+	open, pending := g.openOrders()
+	fmt.Printf("Stop grid %d and cancel its %d open orders\n", g.Id, len(open))
+	if pending > 0 {
+		fmt.Printf("%d orders are still confirming, the order engine cancels them once they show on the book\n", pending)
+	}
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	// the order engine may have moved on while the prompt was open
+	grids_mutex.Lock()
+	var list []Grid
+	if !loadStore(gridStore, &list) {
+		grids_mutex.Unlock()
+		return
+	}
+
+	found = false
+	for i := range list {
+		if list[i].Id != uint64(id) || list[i].Stopped {
+			continue
+		}
+
+		// orders not placed yet are dropped, placed ones stay until they are resolved
+		var keep []GridOrder
+		for _, o := range list[i].Orders {
+			if o.Placed > 0 {
+				keep = append(keep, o)
+			}
+		}
+		list[i].Stopped = true
+		list[i].Orders = keep
+		g, found = list[i], true
+	}
+	if found {
+		saveStore(gridStore, list)
+	}
+	grids_mutex.Unlock()
+
+	if !found {
+		fmt.Printf("grid %d was stopped in the meantime\n", id)
+		return
+	}
+
+	open, _ = g.openOrders()
+
+	getTradePairs()
+	contract := tradePairs[g.Pair].contract
+
+	for _, order := range open {
+		transfers, args := cancelOrder(order)
+		txid, b := d.DeroSafeCallSC(contract, transfers, args)
+		if !b {
+			fmt.Printf("Cancel of order %d failed\n", order)
+			continue
+		}
+		fmt.Printf("Cancel of order %d submitted: txid = %s\n", order, txid)
+		markCancelled(g.Pair, order)
+	}
+}
+
+func gridProfit() {
+	grids_mutex.Lock()
+	defer grids_mutex.Unlock()
+
+	var list []Grid
+	if !loadStore(gridStore, &list) {
+		return
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+
+	fmt.Printf("%5s %-20s %-8s %6s %6s %6s %19s %19s %19s %15s\n\n", "ID", "PAIR", "STATUS", "BUYS", "SELLS", "TRIPS", "PROFIT", "FEES", "NET", "NET (USDT)")
+	var total float64
+	for _, g := range list {
+		symbols := strings.Split(g.Pair, ":")
+		scale := math.Pow10(tokens[symbols[1]].decimals)
+		net := (float64(g.Profit) - float64(g.Fees)) / scale
+		ratio, _ := conversion(symbols[1], "DUSDT")
+		total += net * ratio
+
+		fmt.Printf("%5d %-20s %-8s %6d %6d %6d %19f %19f %19f %15.2f\n", g.Id, g.Pair, g.status(), g.Buys, g.Sells, g.Trips,
+			float64(g.Profit)/scale, float64(g.Fees)/scale, net, net*ratio)
+	}
+	fmt.Println()
+	fmt.Printf("Realized profit %.2f USDT\n", total)
+}
+
+// trackGrid matches the grid's orders to the wallet's orders on the book and
+// queues the opposite order for every confirmed fill. An order whose
+// transaction failed is placed again, one that left the book without a
+// confirmed fill is dropped. A stopped grid cancels its orders as they show
+// on the book and places nothing.
+func trackGrid(g *Grid, pair TradePair) {
+	mine := myOrders(pair)
+	symbols := strings.Split(g.Pair, ":")
+	dec1 := getToken(symbols[0]).decimals
+	dec2 := getToken(symbols[1]).decimals
+
+	claimed := make(map[uint64]bool)
+	for _, o := range g.Orders {
+		if o.Order > 0 {
+			claimed[o.Order] = true
+		}
+	}
+
+	var keep, next []GridOrder
+	for _, o := range g.Orders {
+		if o.Placed == 0 {
+			if !g.Stopped {
+				keep = append(keep, o)
+			}
+			continue
+		}
+
+		price := g.price(o.Level)
+
+		if o.Order == 0 {
+			for k, b := range mine {
+				if !claimed[k] && k > o.After && b.t == o.Side && b.o1 == g.Amount && pair.prices[b.n] == price {
+					o.Order = k
+					claimed[k] = true
+					break
+				}
+			}
+		}
+
+		state, _ := placement(g.Pair, pair, mine, o.Order, o.Side, g.Amount, price, o.Placed)
+		switch state {
+		case placePending:
+			keep = append(keep, o)
+			continue
+		case placeOpen:
+			if g.Stopped {
+				cancelPlaced(g.Pair, pair, o.Order)
+			}
+			keep = append(keep, o)
+			continue
+		case placeFailed:
+			notify("grid %d %s order at %f %s was not placed\n", g.Id, o.Side, float64(price)/priceScale, symbols[1])
+			if !g.Stopped {
+				keep = append(keep, GridOrder{Level: o.Level, Side: o.Side, Counter: o.Counter})
+			}
+			continue
+		case placeCancelled:
+			if !wasCancelled(g.Pair, o.Order) {
+				notify("grid %d %s order %d left the book without a confirmed fill, not replaced\n", g.Id, o.Side, o.Order)
+			}
+			continue
+		}
+
+		value := orderCost(g.Amount, price, dec1, dec2)
+		g.Fees += multDiv(value, pair.fee, 10000)
+
+		counter := GridOrder{Level: o.Level + 1, Side: "sell", Counter: true}
+		if o.Side == "buy" {
+			g.Buys++
+		} else {
+			g.Sells++
+			counter = GridOrder{Level: o.Level - 1, Side: "buy", Counter: true}
+		}
+
+		// a counter order closes a round trip with the fill one level away
+		if o.Counter {
+			other := orderCost(g.Amount, g.price(counter.Level), dec1, dec2)
+			g.Trips++
+			if o.Side == "sell" {
+				g.Profit += value - other
+			} else {
+				g.Profit += other - value
+			}
+		}
+
+		notify("grid %d %s filled at %f %s\n", g.Id, o.Side, float64(price)/priceScale, symbols[1])
+
+		if !g.Stopped && counter.Level >= 0 && counter.Level < g.Levels {
+			next = append(next, counter)
+		}
+	}
+
+	g.Orders = append(keep, next...)
+}
+
+// workGrid places the next pending order of a grid.
+func workGrid(g *Grid, pair TradePair) {
+	for i := range g.Orders {
+		o := &g.Orders[i]
+		if o.Placed > 0 {
+			continue
+		}
+
+		price := g.price(o.Level)
+		transfers, args := sellOrder(g.Pair, g.Amount, price)
+		if o.Side == "buy" {
+			transfers, args = buyOrder(g.Pair, g.Amount, price)
+		}
+
+		txid, b := sendOrder(pair.contract, transfers, args)
+		if !b {
+			notify("grid %d could not place a %s order, retrying\n", g.Id, o.Side)
+			return
+		}
+
+		for k := range pair.orders {
+			if k > o.After {
+				o.After = k
+			}
+		}
+		o.Placed = time.Now().Unix()
+		o.Txid = txid
+		return
+	}
+}
+
+// checkGrids tracks every grid with orders out and places one pending order
+// for each running grid.
+func checkGrids(fetch func() map[string]TradePair) {
+	grids_mutex.Lock()
+	defer grids_mutex.Unlock()
+
+	var list []Grid
+	if !loadStore(gridStore, &list) {
+		return
+	}
+
+	active := false
+	for _, g := range list {
+		if !g.Stopped || len(g.Orders) > 0 {
+			active = true
+		}
+	}
+	if !active {
+		return
+	}
+
+	books := fetch()
+
+	for i := range list {
+		g := &list[i]
+		if g.Stopped && len(g.Orders) == 0 {
+			continue
+		}
+
+		pair := books[g.Pair]
+		if len(pair.contract) == 0 {
+			continue
+		}
+
+		trackGrid(g, pair)
+		if !g.Stopped {
+			workGrid(g, pair)
+		}
+	}
+
+	saveStore(gridStore, list)
+}
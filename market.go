@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// bookLevels aggregates the resting orders of one side ("buy" or "sell"),
+// best price first.
+func bookLevels(pair TradePair, side string) []ordSum {
+	var orders []ordSum
+	for _, order := range pair.orders {
+		if (order.t == "sell") == (side == "sell") {
+			orders = append(orders, ordSum{pair.prices[order.n], order.v1, 0})
+		}
+	}
+
+	if side == "sell" {
+		return tradeBookSum(orders, "fwd")
+	}
+	return tradeBookSum(orders, "rev")
+}
+
+// bookFills walks the levels of side best price first until amt is filled.
+// A limit of 0 takes any price, otherwise levels beyond limit are not taken.
+func bookFills(pair TradePair, side string, amt uint64, limit uint64) (fills []ordSum, filled uint64) {
+	for _, level := range bookLevels(pair, side) {
+		if filled == amt {
+			break
+		}
+		if limit > 0 && ((side == "sell" && level.price > limit) || (side == "buy" && level.price < limit)) {
+			break
+		}
+
+		take := level.amount
+		if take > amt-filled {
+			take = amt - filled
+		}
+		filled += take
+		fills = append(fills, ordSum{level.price, take, filled})
+	}
+
+	return
+}
+
+// averagePrice is the amount weighted price of fills.
+func averagePrice(fills []ordSum) float64 {
+	var value, amount float64
+	for _, f := range fills {
+		value += float64(f.price) * float64(f.amount)
+		amount += float64(f.amount)
+	}
+
+	if amount == 0 {
+		return 0
+	}
+	return value / amount
+}
+
+// protectPrice moves price by the protection band against the taker.
+func protectPrice(price uint64, side string) uint64 {
+	if side == "buy" {
+		return uint64(float64(price) * (1.0 + priceBand/100.0))
+	}
+	return uint64(float64(price) * (1.0 - priceBand/100.0))
+}
+
+func printFills(fills []ordSum, symbols []string) {
+	scale := math.Pow10(tokens[symbols[0]].decimals)
+
+	fmt.Printf("%19s %19s %19s\n", fmt.Sprintf("PRICE (%s)", symbols[1]), fmt.Sprintf("AMOUNT (%s)", symbols[0]), fmt.Sprintf("TOTAL (%s)", symbols[0]))
+	for _, f := range fills {
+		fmt.Printf("%19f %19f %19f\n", float64(f.price)/priceScale, float64(f.amount)/scale, float64(f.total)/scale)
+	}
+}
+
+// printFillFee shows the fee charged on the value of fills.
+func printFillFee(pair TradePair, fills []ordSum, symbols []string) {
+	var value uint64
+	for _, f := range fills {
+		value += orderCost(f.amount, f.price, tokens[symbols[0]].decimals, tokens[symbols[1]].decimals)
+	}
+
+	fee := multDiv(value, pair.fee, 10000)
+	fmt.Printf("Fee %.2f%% of %f %s = %f %s\n", float64(pair.fee)/100.0,
+		float64(value)/math.Pow10(tokens[symbols[1]].decimals), symbols[1],
+		float64(fee)/math.Pow10(tokens[symbols[1]].decimals), symbols[1])
+}
+
+// previewLimit shows how much of a limit order on side matches immediately
+// and how much rests on the book.
+func previewLimit(pair TradePair, symbols []string, side string, amt uint64, price uint64) {
+	book := "sell"
+	if side == "sell" {
+		book = "buy"
+	}
+
+	scale := math.Pow10(tokens[symbols[0]].decimals)
+
+	fills, filled := bookFills(pair, book, amt, price)
+	if len(fills) == 0 {
+		fmt.Printf("No immediate fills, %f %s rests on the book\n", float64(amt)/scale, symbols[0])
+		return
+	}
+
+	fmt.Println("Immediate fills:")
+	printFills(fills, symbols)
+	fmt.Println()
+	fmt.Printf("Filled %f %s at average price %f %s\n", float64(filled)/scale, symbols[0], averagePrice(fills)/priceScale, symbols[1])
+	printFillFee(pair, fills, symbols)
+	fmt.Printf("Resting %f %s @ %f %s\n", float64(amt-filled)/scale, symbols[0], float64(price)/priceScale, symbols[1])
+}
+
+// tradeMarket places a marketable limit order for amount, side is the
+// side of the taker ("buy" or "sell").
+func tradeMarket(words []string, side string) {
+	if len(words) != 2 {
+		fmt.Printf("%s-market requires 2 arguments\n", side)
+		tradeHelp()
+		return
+	}
+
+	getTradePairs()
+
+	pair := tradePairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+	tokenA := tokens[symbols[0]]
+
+	amt_float, err := strconv.ParseFloat(words[1], 64)
+	if err != nil {
+		fmt.Printf("cannot parse amount '%s'\n", words[1])
+		return
+	}
+
+	if amt_float <= 0.0 {
+		fmt.Println("amount must be > 0.0")
+		return
+	}
+
+	amt_64 := uint64(amt_float * math.Pow10(tokenA.decimals))
+
+	// a buy takes the sell side of the book and the other way around
+	book := "sell"
+	if side == "sell" {
+		book = "buy"
+	}
+
+	fills, filled := bookFills(pair, book, amt_64, 0)
+	if len(fills) == 0 {
+		fmt.Printf("no %s orders on the book\n", book)
+		return
+	}
+
+	worst := fills[len(fills)-1].price
+	price_64 := protectPrice(worst, side)
+
+	printFills(fills, symbols)
+	fmt.Println()
+	fmt.Printf("Average price %f %s, worst price %f %s\n", averagePrice(fills)/priceScale, symbols[1], float64(worst)/priceScale, symbols[1])
+	printFillFee(pair, fills, symbols)
+	if filled < amt_64 {
+		fmt.Printf("Book only holds %f %s, the rest rests at the limit price\n", float64(filled)/math.Pow10(tokenA.decimals), symbols[0])
+	}
+
+	if side == "buy" {
+		transfers, args := buyOrder(words[0], amt_64, price_64)
+		submitOrder(pair.contract, transfers, args, fmt.Sprintf("Buy market order %f %s limit %f %s (%.2f%% band)",
+			amt_float, symbols[0], float64(price_64)/priceScale, symbols[1], priceBand))
+	} else {
+		transfers, args := sellOrder(words[0], amt_64, price_64)
+		submitOrder(pair.contract, transfers, args, fmt.Sprintf("Sell market order %f %s limit %f %s (%.2f%% band)",
+			amt_float, symbols[0], float64(price_64)/priceScale, symbols[1], priceBand))
+	}
+}
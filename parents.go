@@ -0,0 +1,522 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	d "github.com/deroholic/derogo"
+)
+
+// ChildOrder is one limit order placed on behalf of a parent order.
+type ChildOrder struct {
+	Order  uint64 `json:"order"` // 0 until seen on the book
+	After  uint64 `json:"after"` // highest order number when placed
+	Amount uint64 `json:"amount"`
+	Price  uint64 `json:"price"`
+	Filled uint64 `json:"filled"`
+	Placed int64  `json:"placed"`
+	Txid   string `json:"txid"`
+	Open   bool   `json:"open"`
+}
+
+// ParentOrder is a TWAP or iceberg order worked by the order engine.
+type ParentOrder struct {
+	Id        uint64       `json:"id"`
+	Kind      string       `json:"kind"`
+	Pair      string       `json:"pair"`
+	Side      string       `json:"side"`
+	Amount    uint64       `json:"amount"`
+	Price     uint64       `json:"price"`   // iceberg limit
+	Visible   uint64       `json:"visible"` // iceberg slice
+	Slices    uint64       `json:"slices"`  // twap slices
+	Start     int64        `json:"start"`
+	Duration  int64        `json:"duration"`
+	Placed    uint64       `json:"placed"`
+	Children  []ChildOrder `json:"children"`
+	Cancelled bool         `json:"cancelled"`
+}
+
+const parentStore = "parents"
+
+var parents_mutex sync.Mutex
+
+func (p *ParentOrder) filled() (filled uint64) {
+	for _, c := range p.Children {
+		filled += c.Filled
+	}
+	return
+}
+
+func (p *ParentOrder) open() bool {
+	for _, c := range p.Children {
+		if c.Open {
+			return true
+		}
+	}
+	return false
+}
+
+// openChildren is the children on the book and the number still confirming.
+func (p *ParentOrder) openChildren() (open []uint64, pending int) {
+	for _, c := range p.Children {
+		if c.Open && c.Order > 0 {
+			open = append(open, c.Order)
+		} else if c.Open {
+			pending++
+		}
+	}
+	return
+}
+
+func (p *ParentOrder) done() bool {
+	return !p.open() && (p.Cancelled || p.Placed >= p.Amount)
+}
+
+func (p *ParentOrder) status() string {
+	if p.Cancelled {
+		if p.open() {
+			return "cancelling"
+		}
+		return "cancelled"
+	}
+	if p.done() {
+		return "done"
+	}
+	return "working"
+}
+
+// newParentId returns the next free parent number.
+func newParentId(list []ParentOrder) uint64 {
+	id := uint64(1)
+	for _, p := range list {
+		if p.Id >= id {
+			id = p.Id + 1
+		}
+	}
+	return id
+}
+
+func saveParent(p ParentOrder) {
+	parents_mutex.Lock()
+	defer parents_mutex.Unlock()
+
+	var list []ParentOrder
+	if !loadStore(parentStore, &list) {
+		return
+	}
+
+	p.Id = newParentId(list)
+	list = append(list, p)
+
+	if saveStore(parentStore, list) {
+		fmt.Printf("%s order %d saved, the order engine places the children\n", p.Kind, p.Id)
+	}
+}
+
+// loadParent reads one parent order without holding the lock afterwards.
+func loadParent(id uint64) (p ParentOrder, found bool) {
+	parents_mutex.Lock()
+	defer parents_mutex.Unlock()
+
+	var list []ParentOrder
+	if !loadStore(parentStore, &list) {
+		return
+	}
+
+	for _, o := range list {
+		if o.Id == id {
+			return o, true
+		}
+	}
+	return
+}
+
+// parseParent reads the pair, side and amount shared by twap and iceberg.
+func parseParent(words []string) (p ParentOrder, ok bool) {
+	getTradePairs()
+
+	if len(tradePairs[words[0]].contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	side := strings.ToLower(words[1])
+	if side != "buy" && side != "sell" {
+		fmt.Printf("side must be buy or sell, not '%s'\n", words[1])
+		return
+	}
+
+	amt_float, err := strconv.ParseFloat(words[2], 64)
+	if err != nil || amt_float <= 0.0 {
+		fmt.Printf("cannot parse amount '%s'\n", words[2])
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+
+	p.Pair = words[0]
+	p.Side = side
+	p.Amount = uint64(amt_float * math.Pow10(tokens[symbols[0]].decimals))
+	p.Start = time.Now().Unix()
+	ok = p.Amount > 0
+
+	return
+}
+
+func tradeTwap(words []string) {
+	if len(words) != 5 {
+		fmt.Println("twap requires 5 arguments")
+		tradeHelp()
+		return
+	}
+
+	p, ok := parseParent(words)
+	if !ok {
+		return
+	}
+
+	duration, err := time.ParseDuration(words[3])
+	if err != nil || duration <= 0 {
+		fmt.Printf("cannot parse duration '%s', use e.g. 30m or 2h\n", words[3])
+		return
+	}
+
+	slices, err := strconv.Atoi(words[4])
+	if err != nil || slices < 1 || uint64(slices) > p.Amount {
+		fmt.Printf("invalid number of slices '%s'\n", words[4])
+		return
+	}
+
+	p.Kind = "twap"
+	p.Duration = int64(duration.Seconds())
+	p.Slices = uint64(slices)
+
+	symbols := strings.Split(p.Pair, ":")
+	fmt.Printf("TWAP %s %f %s in %d slices over %s, each slice a marketable order within %.2f%%\n", p.Side,
+		float64(p.Amount)/math.Pow10(tokens[symbols[0]].decimals), symbols[0], slices, duration, priceBand)
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	saveParent(p)
+}
+
+func tradeIceberg(words []string) {
+	if len(words) != 5 {
+		fmt.Println("iceberg requires 5 arguments")
+		tradeHelp()
+		return
+	}
+
+	p, ok := parseParent(words)
+	if !ok {
+		return
+	}
+
+	symbols := strings.Split(p.Pair, ":")
+
+	price_float, err := strconv.ParseFloat(words[3], 64)
+	if err != nil || price_float <= 0.0 {
+		fmt.Printf("cannot parse amount '%s'\n", words[3])
+		return
+	}
+
+	visible_float, err := strconv.ParseFloat(words[4], 64)
+	if err != nil || visible_float <= 0.0 {
+		fmt.Printf("cannot parse amount '%s'\n", words[4])
+		return
+	}
+
+	p.Kind = "iceberg"
+	p.Price = uint64(price_float * priceScale)
+	p.Visible = uint64(visible_float * math.Pow10(tokens[symbols[0]].decimals))
+
+	if p.Visible == 0 || p.Visible > p.Amount {
+		fmt.Println("visible amount must be > 0.0 and <= amount")
+		return
+	}
+
+	fmt.Printf("Iceberg %s %f %s @ %f %s showing %f %s at a time\n", p.Side,
+		float64(p.Amount)/math.Pow10(tokens[symbols[0]].decimals), symbols[0], price_float, symbols[1],
+		visible_float, symbols[0])
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	saveParent(p)
+}
+
+func tradeParents(words []string) {
+	parents_mutex.Lock()
+	defer parents_mutex.Unlock()
+
+	var list []ParentOrder
+	if !loadStore(parentStore, &list) {
+		return
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+
+	fmt.Printf("%5s %-8s %-20s %4s %19s %19s %19s %8s %-10s\n\n", "ID", "KIND", "PAIR", "SIDE", "AMOUNT", "PLACED", "FILLED", "CHILDREN", "STATUS")
+	for _, p := range list {
+		scale := math.Pow10(tokens[strings.Split(p.Pair, ":")[0]].decimals)
+		fmt.Printf("%5d %-8s %-20s %4s %19f %19f %19f %8d %-10s\n", p.Id, p.Kind, p.Pair, p.Side,
+			float64(p.Amount)/scale, float64(p.Placed)/scale, float64(p.filled())/scale, len(p.Children), p.status())
+	}
+}
+
+func tradeCancelParent(words []string) {
+	if len(words) != 1 {
+		fmt.Println("cancel-parent requires 1 argument")
+		tradeHelp()
+		return
+	}
+
+	id, err := strconv.Atoi(words[0])
+	if err != nil {
+		fmt.Println("invalid parent order number")
+		return
+	}
+
+	p, found := loadParent(uint64(id))
+	if !found {
+		fmt.Printf("parent order %d not found\n", id)
+		return
+	}
+
+	if p.done() {
+		fmt.Printf("parent order %d is already %s\n", id, p.status())
+		return
+	}
+
+	open, pending := p.openChildren()
+	fmt.Printf("Cancel %s order %d and its %d open children\n", p.Kind, p.Id, len(open))
+	if pending > 0 {
+		fmt.Printf("%d children are still confirming, the order engine cancels them once they show on the book\n", pending)
+	}
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	// the order engine may have moved on while the prompt was open
+	parents_mutex.Lock()
+	var list []ParentOrder
+	if !loadStore(parentStore, &list) {
+		parents_mutex.Unlock()
+		return
+	}
+
+	found = false
+	for i := range list {
+		if list[i].Id == uint64(id) && !list[i].done() {
+			list[i].Cancelled = true
+			p, found = list[i], true
+		}
+	}
+	if found {
+		saveStore(parentStore, list)
+	}
+	parents_mutex.Unlock()
+
+	if !found {
+		fmt.Printf("parent order %d finished in the meantime\n", id)
+		return
+	}
+
+	open, _ = p.openChildren()
+
+	contract := tradePairs[p.Pair].contract
+	if len(contract) == 0 {
+		getTradePairs()
+		contract = tradePairs[p.Pair].contract
+	}
+
+	for _, order := range open {
+		transfers, args := cancelOrder(order)
+		txid, b := d.DeroSafeCallSC(contract, transfers, args)
+		if !b {
+			fmt.Printf("Cancel of order %d failed\n", order)
+			continue
+		}
+		fmt.Printf("Cancel of order %d submitted: txid = %s\n", order, txid)
+		markCancelled(p.Pair, order)
+	}
+}
+
+// trackChildren matches children to the wallet's orders on the book and
+// updates their confirmed fills. Children of a cancelled parent are
+// cancelled once they show on the book, a child whose transaction failed is
+// dropped so its amount is placed again.
+func trackChildren(p *ParentOrder, pair TradePair) {
+	mine := myOrders(pair)
+
+	claimed := make(map[uint64]bool)
+	for _, c := range p.Children {
+		if c.Order > 0 {
+			claimed[c.Order] = true
+		}
+	}
+
+	var keep []ChildOrder
+	for _, c := range p.Children {
+		if !c.Open {
+			keep = append(keep, c)
+			continue
+		}
+
+		if c.Order == 0 {
+			for k, o := range mine {
+				if !claimed[k] && k > c.After && o.t == p.Side && o.o1 == c.Amount && pair.prices[o.n] == c.Price {
+					c.Order = k
+					claimed[k] = true
+					break
+				}
+			}
+		}
+
+		state, filled := placement(p.Pair, pair, mine, c.Order, p.Side, c.Amount, c.Price, c.Placed)
+		switch state {
+		case placeOpen:
+			c.Filled = filled
+			if p.Cancelled {
+				cancelPlaced(p.Pair, pair, c.Order)
+			}
+		case placeFilled, placeCancelled:
+			c.Filled = filled
+			c.Open = false
+		case placeFailed:
+			notify("%s order %d child %s was not placed\n", p.Kind, p.Id, c.Txid)
+			p.Placed -= c.Amount
+			continue
+		}
+
+		keep = append(keep, c)
+	}
+
+	p.Children = keep
+}
+
+// placeChild sends the next child of a parent.
+func placeChild(p *ParentOrder, pair TradePair, amt uint64, price uint64) bool {
+	transfers, args := sellOrder(p.Pair, amt, price)
+	if p.Side == "buy" {
+		transfers, args = buyOrder(p.Pair, amt, price)
+	}
+
+	txid, b := sendOrder(pair.contract, transfers, args)
+	if !b {
+		notify("%s order %d could not place a child, retrying\n", p.Kind, p.Id)
+		return false
+	}
+
+	after := uint64(0)
+	for k := range pair.orders {
+		if k > after {
+			after = k
+		}
+	}
+
+	p.Children = append(p.Children, ChildOrder{0, after, amt, price, 0, time.Now().Unix(), txid, true})
+	p.Placed += amt
+
+	return true
+}
+
+// workParent places whatever children are due.
+func workParent(p *ParentOrder, pair TradePair) {
+	left := p.Amount - p.Placed
+	if left == 0 || p.Cancelled {
+		return
+	}
+
+	switch p.Kind {
+	case "twap":
+		elapsed := time.Now().Unix() - p.Start
+		due := p.Slices
+		if elapsed < p.Duration {
+			due = uint64(elapsed)*p.Slices/uint64(p.Duration) + 1
+		}
+		if uint64(len(p.Children)) >= due {
+			return
+		}
+
+		amt := p.Amount / p.Slices
+		if uint64(len(p.Children)) == p.Slices-1 || amt > left {
+			amt = left
+		}
+
+		price, ok := marketablePrice(pair, p.Side, amt)
+		if !ok {
+			notify("twap order %d has no book to trade against, retrying\n", p.Id)
+			return
+		}
+
+		if placeChild(p, pair, amt, price) {
+			notify("twap order %d placed slice %d of %d\n", p.Id, len(p.Children), p.Slices)
+		}
+	case "iceberg":
+		if p.open() {
+			return
+		}
+
+		amt := p.Visible
+		if amt > left {
+			amt = left
+		}
+
+		if placeChild(p, pair, amt, p.Price) {
+			notify("iceberg order %d placed child %d\n", p.Id, len(p.Children))
+		}
+	}
+}
+
+// checkParents tracks and works every parent order still in progress.
+func checkParents(fetch func() map[string]TradePair) {
+	parents_mutex.Lock()
+	defer parents_mutex.Unlock()
+
+	var list []ParentOrder
+	if !loadStore(parentStore, &list) {
+		return
+	}
+
+	active := false
+	for _, p := range list {
+		if !p.done() {
+			active = true
+		}
+	}
+	if !active {
+		return
+	}
+
+	books := fetch()
+
+	for i := range list {
+		p := &list[i]
+		if p.done() {
+			continue
+		}
+
+		pair := books[p.Pair]
+		trackChildren(p, pair)
+		workParent(p, pair)
+
+		if p.done() {
+			symbols := strings.Split(p.Pair, ":")
+			scale := math.Pow10(getToken(symbols[0]).decimals)
+			notify("%s order %d %s, filled %f of %f %s\n", p.Kind, p.Id, p.status(),
+				float64(p.filled())/scale, float64(p.Amount)/scale, symbols[0])
+		}
+	}
+
+	saveStore(parentStore, list)
+}
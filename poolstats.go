@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PairSample is the state of one swap pair at the time of a sample.
+type PairSample struct {
+	Val1   uint64 `json:"val1"`
+	Val2   uint64 `json:"val2"`
+	Shares uint64 `json:"shares"`
+	Adds   uint64 `json:"adds"`
+	Rems   uint64 `json:"rems"`
+	Swaps  uint64 `json:"swaps"`
+}
+
+type PoolSample struct {
+	Time  int64                 `json:"time"`
+	Pairs map[string]PairSample `json:"pairs"`
+}
+
+const poolStore = "poolstats"
+
+// samples older than this are dropped from the store
+const poolHistory = 90 * 24 * time.Hour
+
+type poolStat struct {
+	pair      string
+	swaps     uint64
+	adds      uint64
+	rems      uint64
+	volume    float64 // USDT
+	fees      float64 // USDT
+	liquidity float64 // change in shares outstanding, percent
+	apr       float64
+}
+
+var poolColumns = []string{"pair", "swaps", "adds", "rems", "volume", "fees", "liquidity", "apr"}
+
+// how often the order engine records a sample
+const poolSampleInterval = 10 * time.Minute
+
+var pool_mutex sync.Mutex
+
+// poolSample is the state of every pair in pairs.
+func poolSample(pairs map[string]Pair, now time.Time) PoolSample {
+	sample := PoolSample{now.Unix(), make(map[string]PairSample)}
+	for key, pair := range pairs {
+		sample.Pairs[key] = PairSample{pair.val1, pair.val2, pair.sharesOutstanding, pair.adds, pair.rems, pair.swaps}
+	}
+	return sample
+}
+
+// samplePools appends the current state of every pair to the store, it runs
+// from the order engine so history builds up while cldex is open.
+func samplePools() {
+	pool_mutex.Lock()
+	defer pool_mutex.Unlock()
+
+	var samples []PoolSample
+	if !loadStore(poolStore, &samples) {
+		return
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-poolHistory).Unix()
+	for len(samples) > 0 && samples[0].Time < cutoff {
+		samples = samples[1:]
+	}
+	samples = append(samples, poolSample(fetchPairs(), now))
+
+	saveStore(poolStore, samples)
+}
+
+func poolStats(words []string) {
+	if len(words) > 2 {
+		fmt.Println("poolstats requires at most 2 arguments")
+		printHelp()
+		return
+	}
+
+	column := "apr"
+	window := 24 * time.Hour
+
+	for _, w := range words {
+		if hours, err := strconv.ParseFloat(w, 64); err == nil && hours > 0 {
+			window = time.Duration(hours * float64(time.Hour))
+			continue
+		}
+
+		column = ""
+		for _, c := range poolColumns {
+			if strings.ToLower(w) == c {
+				column = c
+			}
+		}
+		if column == "" {
+			fmt.Printf("unknown column '%s', use one of %s\n", w, strings.Join(poolColumns, ", "))
+			return
+		}
+	}
+
+	getPairs()
+
+	pool_mutex.Lock()
+	var samples []PoolSample
+	ok := loadStore(poolStore, &samples)
+	pool_mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	// compare the pairs now against the oldest sample inside the window, the
+	// window is only covered when that sample is near its start
+	latest := poolSample(pairs, time.Now())
+	since := latest.Time - int64(window.Seconds())
+	start := -1
+	for i, s := range samples {
+		if s.Time >= since {
+			start = i
+			break
+		}
+	}
+	if start == -1 || samples[start].Time-since > int64(2*poolSampleInterval.Seconds()) {
+		covered := time.Duration(0)
+		if len(samples) > 0 {
+			covered = time.Duration(latest.Time-samples[0].Time) * time.Second
+		}
+		fmt.Printf("Insufficient history for a %s window, samples cover %s. Samples are taken every %s while cldex runs.\n",
+			window, covered, poolSampleInterval)
+		return
+	}
+	first := samples[start]
+
+	elapsed := float64(latest.Time - first.Time)
+	if elapsed <= 0 {
+		fmt.Println("Insufficient history, no sample is older than now.")
+		return
+	}
+
+	var stats []poolStat
+	for key, now := range latest.Pairs {
+		then, found := first.Pairs[key]
+		if !found || now.Shares == 0 || then.Shares == 0 {
+			continue
+		}
+
+		symbols := strings.Split(key, ":")
+		ratio, _ := conversion(symbols[1], "DUSDT")
+
+		// retained fees are the only thing that grows sqrt(k) per share
+		growth := growthIndex(now.Val1, now.Val2, now.Shares)/growthIndex(then.Val1, then.Val2, then.Shares) - 1.0
+		value := 2.0 * float64(now.Val2) / math.Pow10(tokens[symbols[1]].decimals) * ratio
+
+		stat := poolStat{pair: key}
+		stat.swaps = now.Swaps - then.Swaps
+		stat.adds = now.Adds - then.Adds
+		stat.rems = now.Rems - then.Rems
+		stat.fees = value * growth
+		if fee := pairs[key].fee; fee > 0 {
+			stat.volume = stat.fees / (float64(fee) / 10000.0)
+		}
+		stat.liquidity = (float64(now.Shares)/float64(then.Shares) - 1.0) * 100.0
+		stat.apr = growth * (365 * 24 * 3600 / elapsed) * 100.0
+
+		stats = append(stats, stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		switch column {
+		case "swaps":
+			return a.swaps > b.swaps
+		case "adds":
+			return a.adds > b.adds
+		case "rems":
+			return a.rems > b.rems
+		case "volume":
+			return a.volume > b.volume
+		case "fees":
+			return a.fees > b.fees
+		case "liquidity":
+			return a.liquidity > b.liquidity
+		case "apr":
+			return a.apr > b.apr
+		}
+		return a.pair < b.pair
+	})
+
+	fmt.Printf("Pool statistics over %s (%d samples)\n\n", time.Duration(elapsed)*time.Second, len(samples)-start+1)
+	fmt.Printf("%-20s %7s %7s %7s %15s %15s %10s %9s\n\n", "PAIR", "SWAPS", "ADDS", "REMS", "VOLUME (USDT)", "FEES (USDT)", "LIQUIDITY", "APR")
+	for _, s := range stats {
+		fmt.Printf("%-20s %7d %7d %7d %15.2f %15.2f %9.2f%% %8.2f%%\n",
+			s.pair, s.swaps, s.adds, s.rems, s.volume, s.fees, s.liquidity, s.apr)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	d "github.com/deroholic/derogo"
+)
+
+// LiquidityEntry is one AddLiquidity or RemoveLiquidity submitted by the
+// wallet, with the pair state it was priced against.
+type LiquidityEntry struct {
+	Time        int64  `json:"time"`
+	Pair        string `json:"pair"`
+	Kind        string `json:"kind"`
+	Amt1        uint64 `json:"amt1"`
+	Amt2        uint64 `json:"amt2"`
+	Shares      uint64 `json:"shares"`
+	Val1        uint64 `json:"val1"`
+	Val2        uint64 `json:"val2"`
+	Outstanding uint64 `json:"outstanding"`
+	Txid        string `json:"txid"`
+}
+
+const positionStore = "positions"
+
+func recordLiquidity(key string, kind string, amt1 uint64, amt2 uint64, shares uint64, txid string) {
+	var ledger []LiquidityEntry
+	if !loadStore(positionStore, &ledger) {
+		return
+	}
+
+	pair := pairs[key]
+	ledger = append(ledger, LiquidityEntry{time.Now().Unix(), key, kind, amt1, amt2, shares,
+		pair.val1, pair.val2, pair.sharesOutstanding, txid})
+
+	saveStore(positionStore, ledger)
+}
+
+// growthIndex is sqrt(k) per share, it only rises as swap fees are retained.
+func growthIndex(val1 uint64, val2 uint64, outstanding uint64) float64 {
+	if outstanding == 0 {
+		return 0
+	}
+
+	return math.Sqrt(float64(val1)*float64(val2)) / float64(outstanding)
+}
+
+type position struct {
+	shares uint64
+	dep1   float64 // deposited amounts still attributed to the shares
+	dep2   float64
+	basis  float64 // deposits valued in the second symbol at entry
+	index  float64 // share weighted growth index at entry
+}
+
+func displayPositions() {
+	var ledger []LiquidityEntry
+	if !loadStore(positionStore, &ledger) {
+		return
+	}
+
+	if len(ledger) == 0 {
+		fmt.Println("No liquidity recorded yet.")
+		return
+	}
+
+	getPairs()
+
+	held := make(map[string]*position)
+	for _, e := range ledger {
+		p := held[e.Pair]
+		if p == nil {
+			p = &position{}
+			held[e.Pair] = p
+		}
+
+		if e.Kind == "add" {
+			idx := growthIndex(e.Val1, e.Val2, e.Outstanding)
+			if idx == 0 {
+				// first deposit, the pool is worth exactly the deposit
+				idx = growthIndex(e.Amt1, e.Amt2, e.Shares)
+			}
+			if e.Shares > 0 {
+				p.index = (p.index*float64(p.shares) + idx*float64(e.Shares)) / float64(p.shares+e.Shares)
+			}
+
+			price := float64(e.Amt2) / float64(e.Amt1)
+			if e.Val1 > 0 {
+				price = float64(e.Val2) / float64(e.Val1)
+			}
+
+			p.shares += e.Shares
+			p.dep1 += float64(e.Amt1)
+			p.dep2 += float64(e.Amt2)
+			p.basis += float64(e.Amt1)*price + float64(e.Amt2)
+		} else if p.shares > 0 {
+			burned := e.Shares
+			if burned > p.shares {
+				burned = p.shares
+			}
+			keep := 1.0 - float64(burned)/float64(p.shares)
+
+			p.shares -= burned
+			p.dep1 *= keep
+			p.dep2 *= keep
+			p.basis *= keep
+		}
+	}
+
+	keys := make([]string, 0, len(held))
+	for k := range held {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%-20s %19s %19s %19s %9s %19s\n\n", "PAIR", "COST BASIS", "VALUE", "HOLD VALUE", "IL", "FEES EARNED")
+	for _, key := range keys {
+		p := held[key]
+		pair := pairs[key]
+		if p.shares == 0 || p.index == 0 || pair.sharesOutstanding == 0 || pair.val1 == 0 {
+			continue
+		}
+
+		symbols := strings.Split(key, ":")
+		scale := math.Pow10(tokens[symbols[1]].decimals)
+
+		shares := p.shares
+		if myShares := d.DeroGetSCBal(pair.contract); myShares < shares {
+			shares = myShares
+		}
+
+		my1, my2 := ammBurnShares(shares, pair.val1, pair.val2, pair.sharesOutstanding)
+		price := float64(pair.val2) / float64(pair.val1)
+
+		value := float64(my1)*price + float64(my2)
+		hold := p.dep1*price + p.dep2
+
+		growth := growthIndex(pair.val1, pair.val2, pair.sharesOutstanding) / p.index
+		noFees := value / growth
+		il := (noFees/hold - 1.0) * 100.0
+
+		fmt.Printf("%-20s %19f %19f %19f %8.3f%% %19f %s\n", key,
+			p.basis/scale, value/scale, hold/scale, il, (value-noFees)/scale, symbols[1])
+	}
+}
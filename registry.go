@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	d "github.com/deroholic/derogo"
+	"github.com/deroproject/derohe/rpc"
+)
+
+// waitForRegistry polls the swap registry until key is set.
+func waitForRegistry(key string) (string, bool) {
+	deadline := time.Now().Add(hopTimeout)
+
+	for time.Now().Before(deadline) {
+		swapVars, swapValid := d.DeroGetVars(swapRegistry)
+		if swapValid {
+			if scid, found := swapVars[key]; found {
+				return scid.(string), true
+			}
+		}
+		time.Sleep(time.Second)
+	}
+
+	return "", false
+}
+
+// registerPair asks the swap registry to create a pair, kind is "p" for swap
+// pairs and "c" for trade pairs as in the registry keys.
+func registerPair(words []string, kind string, entrypoint string) {
+	if len(words) != 1 {
+		fmt.Printf("%s requires 1 argument\n", strings.ToLower(entrypoint))
+		printHelp()
+		return
+	}
+
+	getTokens()
+	getPairs()
+	getTradePairs()
+
+	symbols := strings.Split(words[0], ":")
+	if len(symbols) != 2 || symbols[0] == symbols[1] {
+		fmt.Printf("invalid pair '%s', expected <symbol1>:<symbol2>\n", words[0])
+		return
+	}
+
+	for _, sym := range symbols {
+		if !tokens[sym].swapable {
+			fmt.Printf("token '%s' is not registered for swaps\n", sym)
+			return
+		}
+	}
+
+	reverse := symbols[1] + ":" + symbols[0]
+	if kind == "p" && (len(pairs[words[0]].contract) > 0 || len(pairs[reverse].contract) > 0) {
+		fmt.Printf("pair '%s' is already registered\n", words[0])
+		return
+	}
+	if kind == "c" && (len(tradePairs[words[0]].contract) > 0 || len(tradePairs[reverse].contract) > 0) {
+		fmt.Printf("trade pair '%s' is already registered\n", words[0])
+		return
+	}
+
+	var transfers []rpc.Transfer
+	var args rpc.Arguments
+	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, entrypoint})
+	args = append(args, rpc.Argument{"sym1", rpc.DataString, symbols[0]})
+	args = append(args, rpc.Argument{"sym2", rpc.DataString, symbols[1]})
+
+	ge, ge_valid := d.DeroEstimateGas(swapRegistry, transfers, args, 0)
+	if !ge_valid || ge.Status != "OK" {
+		fmt.Printf("Error: %+s\n", ge.Status)
+		return
+	}
+
+	fmt.Printf("%s %s via registry %s\n", entrypoint, words[0], swapRegistry)
+	fmt.Printf("Estimated gas compute %d, storage %d\n", ge.GasCompute, ge.GasStorage)
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	txid, b := d.DeroSafeCallSC(swapRegistry, transfers, args)
+
+	if !b {
+		fmt.Println("Transaction failed.")
+		return
+	}
+
+	fmt.Printf("Transaction submitted: txid = %s, fees = %d\n", txid, ge.GasStorage)
+	fmt.Println("Waiting for the registry to list the new pair...")
+
+	key := kind + ":" + words[0]
+	scid, found := waitForRegistry(key)
+	if !found {
+		fmt.Printf("Registry key '%s' did not appear, check the transaction\n", key)
+		return
+	}
+
+	fmt.Printf("%s registered, contract: %s\n", words[0], scid)
+}
+
+func createPair(words []string) {
+	registerPair(words, "p", "CreatePair")
+}
+
+func createTradePair(words []string) {
+	registerPair(words, "c", "CreateTradePair")
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	d "github.com/deroholic/derogo"
+)
+
+// how long to wait for the output of one hop to reach the wallet
+const hopTimeout = 5 * time.Minute
+
+type hop struct {
+	pair   string
+	from   string
+	to     string
+	in     uint64
+	out    uint64
+	fee    uint64  // taken from the output, in units of to
+	impact float64 // price impact in percent
+}
+
+// findPair returns the registered pair joining two symbols, in either order.
+func findPair(sym1 string, sym2 string) (string, bool) {
+	if len(pairs[sym1+":"+sym2].contract) > 0 {
+		return sym1 + ":" + sym2, true
+	}
+	if len(pairs[sym2+":"+sym1].contract) > 0 {
+		return sym2 + ":" + sym1, true
+	}
+
+	return "", false
+}
+
+type route struct {
+	path []string
+	hops []hop
+	out  uint64
+}
+
+// candidatePaths enumerates the simple paths of at most limit pairs between sym1 and sym2.
+func candidatePaths(sym1 string, sym2 string, limit int) (paths [][]string) {
+	adjacent := make(map[string][]string)
+	for key, pair := range pairs {
+		if pair.val1 == 0 || pair.val2 == 0 {
+			continue
+		}
+		s := strings.Split(key, ":")
+		adjacent[s[0]] = append(adjacent[s[0]], s[1])
+		adjacent[s[1]] = append(adjacent[s[1]], s[0])
+	}
+	for _, next := range adjacent {
+		sort.Strings(next)
+	}
+
+	visited := map[string]bool{sym1: true}
+	path := []string{sym1}
+
+	var walk func(sym string)
+	walk = func(sym string) {
+		if sym == sym2 {
+			paths = append(paths, append([]string(nil), path...))
+			return
+		}
+		if len(path) > limit {
+			return
+		}
+		for _, next := range adjacent[sym] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			path = append(path, next)
+			walk(next)
+			path = path[:len(path)-1]
+			visited[next] = false
+		}
+	}
+	walk(sym1)
+
+	return
+}
+
+// bestRoutes simulates amt along every candidate path, best output first.
+func bestRoutes(sym1 string, sym2 string, amt uint64) (routes []route) {
+	if sym1 == sym2 {
+		return
+	}
+
+	for _, path := range candidatePaths(sym1, sym2, maxHops) {
+		hops, ok := simulateRoute(path, amt)
+		if ok {
+			routes = append(routes, route{path, hops, hops[len(hops)-1].out})
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].out > routes[j].out })
+
+	return
+}
+
+func printRoutes(routes []route) {
+	if len(routes) > topRoutes {
+		routes = routes[:topRoutes]
+	}
+
+	for i, r := range routes {
+		to := r.path[len(r.path)-1]
+		fmt.Printf("  %d) %-40s %19f %s\n", i+1, strings.Join(r.path, " => "),
+			d.DeroFormatMoneyPrecision(r.out, tokens[to].decimals), to)
+	}
+}
+
+// poolReserves returns the reserves of pair key as seen swapping in from.
+func poolReserves(key string, from string) (valIn uint64, valOut uint64) {
+	pair := pairs[key]
+
+	if from == strings.Split(key, ":")[1] {
+		return pair.val2, pair.val1
+	}
+	return pair.val1, pair.val2
+}
+
+// swapOutput simulates swapping amt of symbol from through pair key.
+func swapOutput(key string, from string, amt uint64) uint64 {
+	valIn, valOut := poolReserves(key, from)
+	return ammAmountOut(amt, valIn, valOut, pairs[key].fee)
+}
+
+// swapInput returns the smallest amount of the other symbol that buys at
+// least amt of symbol to through pair key.
+func swapInput(key string, to string, amt uint64) (uint64, bool) {
+	valOut, valIn := poolReserves(key, to)
+	return ammAmountIn(amt, valIn, valOut, pairs[key].fee)
+}
+
+// simulateRoute walks path hop by hop, feeding each output into the next hop.
+func simulateRoute(path []string, amt uint64) (hops []hop, ok bool) {
+	for i := 1; i < len(path); i++ {
+		key, found := findPair(path[i-1], path[i])
+		if !found {
+			return
+		}
+
+		out := swapOutput(key, path[i-1], amt)
+		if out == 0 {
+			return
+		}
+
+		valIn, valOut := poolReserves(key, path[i-1])
+		fee := ammAmountOut(amt, valIn, valOut, 0) - out
+
+		hops = append(hops, hop{key, path[i-1], path[i], amt, out, fee, ammPriceImpact(amt, valIn)})
+		amt = out
+	}
+
+	ok = len(hops) > 0
+	return
+}
+
+// waitForBalance polls the wallet until its balance of contract rises above before.
+func waitForBalance(contract string, before uint64) (uint64, bool) {
+	deadline := time.Now().Add(hopTimeout)
+
+	for time.Now().Before(deadline) {
+		bal := d.DeroGetSCBal(contract)
+		if bal > before {
+			return bal, true
+		}
+		time.Sleep(time.Second)
+	}
+
+	return 0, false
+}
+
+// spotRate is the marginal rate of a hop before fees, in atomic units.
+func spotRate(h hop) float64 {
+	valIn, valOut := poolReserves(h.pair, h.from)
+	return float64(valOut) / float64(valIn)
+}
+
+func printHops(hops []hop) {
+	for i, h := range hops {
+		fmt.Printf("  hop %d %-20s %f %s => %f %s\n", i+1, h.pair,
+			d.DeroFormatMoneyPrecision(h.in, tokens[h.from].decimals), h.from,
+			d.DeroFormatMoneyPrecision(h.out, tokens[h.to].decimals), h.to)
+	}
+}
+
+func swapRoute(words []string, slippage float64) {
+	getPairs()
+
+	from := words[0]
+	to := words[2]
+
+	bal := d.DeroGetSCBal(tokens[from].contract)
+
+	amt, ok := parseAmount(words[1], tokens[from].decimals, bal)
+	if !ok {
+		return
+	}
+
+	if amt > bal {
+		fmt.Println("insufficient funds")
+		return
+	}
+
+	routes := bestRoutes(from, to, amt)
+	if len(routes) == 0 {
+		fmt.Printf("Cannot find path between '%s' and '%s'\n", from, to)
+		return
+	}
+
+	fmt.Println("Routes:")
+	printRoutes(routes)
+	fmt.Println()
+
+	path := routes[0].path
+	hops := routes[0].hops
+
+	fmt.Printf("%s\n", strings.Join(path, " => "))
+	printHops(hops)
+	fmt.Printf("Swapping %f %s for %f %s fees included\n",
+		d.DeroFormatMoneyPrecision(amt, tokens[from].decimals), from,
+		d.DeroFormatMoneyPrecision(hops[len(hops)-1].out, tokens[to].decimals), to)
+
+	minimum := minReceived(hops[len(hops)-1].out, slippage)
+	fmt.Printf("Minimum received %f %s (max slippage %.2f%%)\n", d.DeroFormatMoneyPrecision(minimum, tokens[to].decimals), to, slippage)
+
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	getPairs()
+
+	fresh, ok := simulateRoute(path, amt)
+	if !ok || fresh[len(fresh)-1].out < minimum {
+		fmt.Println("Reserves moved past the slippage tolerance, refusing.")
+		fmt.Println("Re-quoting...")
+		swapRoute(words, slippage)
+		return
+	}
+
+	executeRoute(hops, slippage)
+}
+
+// executeRoute submits the hops in order, feeding each hop with what the
+// previous one delivered. It stops at the first hop that cannot proceed.
+func executeRoute(hops []hop, slippage float64) bool {
+	amt := hops[0].in
+
+	for i, h := range hops {
+		if i > 0 {
+			getPairs()
+
+			// scale the previewed hop to what actually arrived
+			hopMin := minReceived(multDiv(h.out, amt, h.in), slippage)
+			if swapOutput(h.pair, h.from, amt) < hopMin {
+				fmt.Printf("Reserves of %s moved past the slippage tolerance, route stopped holding %f %s\n", h.pair,
+					d.DeroFormatMoneyPrecision(amt, tokens[h.from].decimals), h.from)
+				return false
+			}
+			h.in = amt
+		}
+
+		before := d.DeroGetSCBal(tokens[h.to].contract)
+
+		txid, b := callSwap(h.pair, h.from, h.in)
+		if !b {
+			fmt.Printf("Hop %d (%s) failed, route stopped holding %f %s\n", i+1, h.pair,
+				d.DeroFormatMoneyPrecision(h.in, tokens[h.from].decimals), h.from)
+			return false
+		}
+
+		fmt.Printf("Hop %d submitted: txid = %s\n", i+1, txid)
+
+		if i == len(hops)-1 {
+			break
+		}
+
+		fmt.Printf("Waiting for %s to arrive...\n", h.to)
+		after, arrived := waitForBalance(tokens[h.to].contract, before)
+		if !arrived {
+			fmt.Printf("Timed out waiting for hop %d, route stopped before %s\n", i+1, hops[i+1].pair)
+			return false
+		}
+
+		amt = after - before
+	}
+
+	return true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// swaps with a larger price impact than this are refused outright
+const maxPriceImpact = 40.0
+
+// session defaults, changed with the set command
+var maxSlippage = 1.0
+var maxHops = 3
+var topRoutes = 3
+var priceBand = 1.0
+
+func printSettings() {
+	fmt.Printf("slippage %.2f%%\n", maxSlippage)
+	fmt.Printf("hops     %d\n", maxHops)
+	fmt.Printf("routes   %d\n", topRoutes)
+	fmt.Printf("band     %.2f%%\n", priceBand)
+
+	enabled, interval := watchSettings()
+	if enabled {
+		fmt.Printf("watch    on, every %s\n", interval)
+	} else {
+		fmt.Printf("watch    off\n")
+	}
+}
+
+// parseSlippage accepts a tolerance like "0.5" or "0.5%".
+func parseSlippage(str string) (float64, bool) {
+	slip, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+	if err != nil || slip < 0.0 || slip >= 100.0 {
+		fmt.Printf("invalid slippage '%s', must be >= 0.0 and < 100.0\n", str)
+		return 0, false
+	}
+
+	return slip, true
+}
+
+// minReceived is the smallest output accepted for an expected amount.
+func minReceived(expected uint64, slip float64) uint64 {
+	return multDiv(expected, 10000-uint64(slip*100.0), 10000)
+}
+
+func setOption(words []string) {
+	if len(words) == 0 {
+		printSettings()
+		return
+	}
+
+	if len(words) == 3 && strings.ToLower(words[0]) == "watch" && strings.ToLower(words[1]) == "interval" {
+		secs, err := strconv.Atoi(words[2])
+		if err != nil || secs < 1 {
+			fmt.Printf("invalid watch interval '%s', must be >= 1 second\n", words[2])
+			return
+		}
+		watch_mutex.Lock()
+		watchInterval = time.Duration(secs) * time.Second
+		watch_mutex.Unlock()
+
+		printSettings()
+		return
+	}
+
+	if len(words) != 2 {
+		fmt.Println("set requires 2 arguments")
+		printHelp()
+		return
+	}
+
+	switch strings.ToLower(words[0]) {
+	case "slippage":
+		slip, ok := parseSlippage(words[1])
+		if !ok {
+			return
+		}
+		maxSlippage = slip
+	case "band":
+		band, ok := parseSlippage(words[1])
+		if !ok {
+			return
+		}
+		priceBand = band
+	case "hops", "routes":
+		n, err := strconv.Atoi(words[1])
+		if err != nil || n < 1 {
+			fmt.Printf("invalid %s '%s', must be >= 1\n", words[0], words[1])
+			return
+		}
+		if strings.ToLower(words[0]) == "hops" {
+			maxHops = n
+		} else {
+			topRoutes = n
+		}
+	case "watch":
+		switch strings.ToLower(words[1]) {
+		case "on", "off":
+			watch_mutex.Lock()
+			watchEnabled = strings.ToLower(words[1]) == "on"
+			watch_mutex.Unlock()
+		default:
+			fmt.Printf("invalid watch '%s', must be on or off\n", words[1])
+			return
+		}
+	default:
+		fmt.Printf("unknown setting '%s'\n", words[0])
+		return
+	}
+
+	printSettings()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	d "github.com/deroholic/derogo"
+)
+
+// number of chunks the input is divided into when splitting
+const splitSteps = 100
+
+type leg struct {
+	path []string
+	hops []hop
+	in   uint64
+	out  uint64
+}
+
+// disjointRoutes picks the best routes that share no pair with a better one,
+// so each leg can be simulated against untouched reserves.
+func disjointRoutes(routes []route, limit int) (out []route) {
+	used := make(map[string]bool)
+
+	for _, r := range routes {
+		if len(out) == limit {
+			break
+		}
+
+		overlap := false
+		for _, h := range r.hops {
+			if used[h.pair] {
+				overlap = true
+			}
+		}
+		if overlap {
+			continue
+		}
+
+		for _, h := range r.hops {
+			used[h.pair] = true
+		}
+		out = append(out, r)
+	}
+
+	return
+}
+
+// splitAmount hands out amt chunk by chunk to whichever route gains the most
+// from it, which converges on equal marginal prices across the routes.
+func splitAmount(routes []route, amt uint64) (legs []leg, total uint64) {
+	if len(routes) == 0 {
+		return
+	}
+
+	alloc := make([]uint64, len(routes))
+	outs := make([]uint64, len(routes))
+
+	chunk := amt / splitSteps
+	if chunk == 0 {
+		chunk = amt
+	}
+
+	for left := amt; left > 0; {
+		c := chunk
+		if left < 2*chunk {
+			c = left
+		}
+
+		// a chunk too small to come out of a route gains nothing there
+		best := -1
+		var bestOut, bestGain uint64
+		for i, r := range routes {
+			out := outs[i]
+			if hops, ok := simulateRoute(r.path, alloc[i]+c); ok {
+				out = hops[len(hops)-1].out
+			}
+			if best == -1 || out-outs[i] > bestGain {
+				best, bestOut, bestGain = i, out, out-outs[i]
+			}
+		}
+
+		alloc[best] += c
+		outs[best] = bestOut
+		left -= c
+	}
+
+	for i, r := range routes {
+		if alloc[i] == 0 {
+			continue
+		}
+		hops, ok := simulateRoute(r.path, alloc[i])
+		if !ok {
+			return singleLeg(routes[0], amt)
+		}
+		legs = append(legs, leg{r.path, hops, alloc[i], outs[i]})
+		total += outs[i]
+	}
+
+	return
+}
+
+// singleLeg sends all of amt down r.
+func singleLeg(r route, amt uint64) (legs []leg, total uint64) {
+	hops, ok := simulateRoute(r.path, amt)
+	if !ok {
+		return nil, 0
+	}
+
+	total = hops[len(hops)-1].out
+	return []leg{{r.path, hops, amt, total}}, total
+}
+
+func printLegs(legs []leg) {
+	for i, l := range legs {
+		from := l.path[0]
+		to := l.path[len(l.path)-1]
+		fmt.Printf("  leg %d %-40s %19f %s => %19f %s\n", i+1, strings.Join(l.path, " => "),
+			d.DeroFormatMoneyPrecision(l.in, tokens[from].decimals), from,
+			d.DeroFormatMoneyPrecision(l.out, tokens[to].decimals), to)
+	}
+}
+
+func swapSplit(words []string) {
+	if len(words) != 3 && len(words) != 4 {
+		fmt.Println("swapsplit requires 3 or 4 arguments")
+		printHelp()
+		return
+	}
+
+	slippage := maxSlippage
+	if len(words) == 4 {
+		var ok bool
+		slippage, ok = parseSlippage(words[3])
+		if !ok {
+			return
+		}
+	}
+
+	getPairs()
+
+	from := words[0]
+	to := words[2]
+
+	bal := d.DeroGetSCBal(tokens[from].contract)
+
+	amt, ok := parseAmount(words[1], tokens[from].decimals, bal)
+	if !ok {
+		return
+	}
+
+	if amt > bal {
+		fmt.Println("insufficient funds")
+		return
+	}
+
+	routes := bestRoutes(from, to, amt)
+	if len(routes) == 0 {
+		fmt.Printf("Cannot find path between '%s' and '%s'\n", from, to)
+		return
+	}
+
+	legs, total := splitAmount(disjointRoutes(routes, topRoutes), amt)
+	if len(legs) == 0 {
+		fmt.Println("route has no liquidity")
+		return
+	}
+
+	single := routes[0].out
+	gain := float64(0)
+	if single > 0 {
+		gain = (float64(total)/float64(single) - 1.0) * 100.0
+	}
+
+	printLegs(legs)
+	fmt.Printf("Swapping %f %s for %f %s fees included\n",
+		d.DeroFormatMoneyPrecision(amt, tokens[from].decimals), from,
+		d.DeroFormatMoneyPrecision(total, tokens[to].decimals), to)
+	fmt.Printf("Single route %s gives %f %s, split improves by %f%%\n", strings.Join(routes[0].path, " => "),
+		d.DeroFormatMoneyPrecision(single, tokens[to].decimals), to, gain)
+
+	minimum := minReceived(total, slippage)
+	fmt.Printf("Minimum received %f %s (max slippage %.2f%%)\n", d.DeroFormatMoneyPrecision(minimum, tokens[to].decimals), to, slippage)
+
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	getPairs()
+
+	fresh := uint64(0)
+	for _, l := range legs {
+		hops, ok := simulateRoute(l.path, l.in)
+		if ok {
+			fresh += hops[len(hops)-1].out
+		}
+	}
+
+	if fresh < minimum {
+		fmt.Println("Reserves moved past the slippage tolerance, refusing.")
+		fmt.Println("Re-quoting...")
+		swapSplit(words)
+		return
+	}
+
+	for i, l := range legs {
+		fmt.Printf("Leg %d: %s\n", i+1, strings.Join(l.path, " => "))
+		if !executeRoute(l.hops, slippage) {
+			fmt.Printf("Leg %d stopped, remaining legs not submitted\n", i+1)
+			return
+		}
+	}
+}
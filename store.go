@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	d "github.com/deroholic/derogo"
+)
+
+// storePath names the local file holding one kind of record for the open wallet.
+func storePath(name string) string {
+	addr := d.DeroGetAddress()
+	if len(addr) > 8 {
+		addr = addr[len(addr)-8:]
+	}
+
+	return fmt.Sprintf("cldex_%s_%s.json", name, addr)
+}
+
+// loadStore reads a store into v, a missing store leaves v untouched.
+func loadStore(name string, v interface{}) bool {
+	data, err := os.ReadFile(storePath(name))
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		fmt.Printf("cannot read %s: %s\n", storePath(name), err)
+		return false
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		fmt.Printf("cannot parse %s: %s\n", storePath(name), err)
+		return false
+	}
+
+	return true
+}
+
+// saveStore replaces a store with v.
+func saveStore(name string, v interface{}) bool {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Printf("cannot encode %s: %s\n", name, err)
+		return false
+	}
+
+	path := storePath(name)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		fmt.Printf("cannot write %s: %s\n", path, err)
+		return false
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		fmt.Printf("cannot write %s: %s\n", path, err)
+		return false
+	}
+
+	return true
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 
 	d "github.com/deroholic/derogo"
 	"github.com/deroproject/derohe/rpc"
@@ -37,9 +38,10 @@ var tokens map[string]Token
 var pairs map[string]Pair
 var tokenList []string
 var tokenGraph *graph.Mutable
+var tokens_mutex sync.RWMutex
 
 func getTokens() {
-	tokens = make(map[string]Token)
+	toks := make(map[string]Token)
 	n := 0
 
 	// bridgeable tokens
@@ -62,7 +64,7 @@ func getTokens() {
 				dec_str, _ := d.DeroGetVar(value.(string), "decimals")
 				tok.decimals, _ = strconv.Atoi(dec_str)
 
-				tokens[s[1]] = tok
+				toks[s[1]] = tok
 			}
 		}
 	}
@@ -73,7 +75,7 @@ func getTokens() {
 		for key, value := range swapVars {
 			s := strings.Split(key, ":")
 			if s[0] == "t" && s[2] == "c" {
-				var tok Token = tokens[s[1]]
+				var tok Token = toks[s[1]]
 
 				if tok == (Token{}) {
 					tok.n = n
@@ -82,25 +84,58 @@ func getTokens() {
 
 					dec_str, _ := d.DeroGetVar(swapRegistry, "t:"+s[1]+":d")
 					tok.decimals, _ = strconv.Atoi(dec_str)
-					tokenList = append(tokenList, s[1])
 				}
 
 				tok.swapable = true
-				tokens[s[1]] = tok
+				toks[s[1]] = tok
 			}
 		}
 	}
 
 	// build list
-	tokenList = make([]string, len(tokens))
-	for k, v := range tokens {
-		tokenList[v.n] = k
+	list := make([]string, len(toks))
+	for k, v := range toks {
+		list[v.n] = k
 	}
+
+	// the order engine and watcher read tokens while this runs
+	tokens_mutex.Lock()
+	tokens = toks
+	tokenList = list
+	tokens_mutex.Unlock()
+}
+
+// getToken reads one token, for use from background tasks.
+func getToken(sym string) Token {
+	tokens_mutex.RLock()
+	defer tokens_mutex.RUnlock()
+
+	return tokens[sym]
 }
 
 func getPairs() {
-	pairs = make(map[string]Pair)
+	pairs = fetchPairs()
 	tokenGraph = graph.New(len(tokens))
+
+	for key, pair := range pairs {
+		if pair.val1 > 0 {
+			s := strings.Split(key, ":")
+			tok1 := tokens[s[0]]
+			tok2 := tokens[s[1]]
+
+			val1_float := float64(pair.val1) / math.Pow(10, float64(tok1.decimals))
+			val2_float := float64(pair.val2) / math.Pow(10, float64(tok2.decimals))
+
+			tokenGraph.AddCost(tok1.n, tok2.n, int64(val2_float/val1_float*math.Pow(10, 7)))
+			tokenGraph.AddCost(tok2.n, tok1.n, int64(val1_float/val2_float*math.Pow(10, 7)))
+		}
+	}
+}
+
+// fetchPairs reads every swap pair without touching pairs, for use from
+// background tasks.
+func fetchPairs() map[string]Pair {
+	pairs := make(map[string]Pair)
 	swapVars, swapValid := d.DeroGetVars(swapRegistry)
 
 	if swapValid {
@@ -140,20 +175,11 @@ func getPairs() {
 				pair.sharesOutstanding = uint64(shares)
 
 				pairs[s[1]+":"+s[2]] = pair
-
-				if pair.val1 > 0 {
-					tok1 := tokens[s[1]]
-					tok2 := tokens[s[2]]
-
-					val1_float := float64(pair.val1) / math.Pow(10, float64(tok1.decimals))
-					val2_float := float64(pair.val2) / math.Pow(10, float64(tok2.decimals))
-
-					tokenGraph.AddCost(tok1.n, tok2.n, int64(val2_float/val1_float*math.Pow(10, 7)))
-					tokenGraph.AddCost(tok2.n, tok1.n, int64(val1_float/val2_float*math.Pow(10, 7)))
-				}
 			}
 		}
 	}
+
+	return pairs
 }
 
 func displayTokens() {
@@ -249,8 +275,13 @@ func conversion(sym1 string, sym2 string) (ratio float64, path string) {
 func quote(words []string) {
 	getPairs()
 
+	if len(words) == 3 {
+		quoteAmount(words)
+		return
+	}
+
 	if len(words) != 2 {
-		fmt.Println("quote requires 2 arguments")
+		fmt.Println("quote requires 2 or 3 arguments")
 		printHelp()
 		return
 	}
@@ -265,6 +296,57 @@ func quote(words []string) {
 	fmt.Printf("1 %s == %0.7f %s\n", words[0], ratio, words[1])
 }
 
+func quoteAmount(words []string) {
+	from := words[0]
+	to := words[2]
+
+	amt, ok := parseAmount(words[1], tokens[from].decimals, d.DeroGetSCBal(tokens[from].contract))
+	if !ok {
+		return
+	}
+
+	routes := bestRoutes(from, to, amt)
+	if len(routes) == 0 {
+		fmt.Printf("Cannot find path between '%s' and '%s'\n", from, to)
+		return
+	}
+
+	path := routes[0].path
+	hops := routes[0].hops
+
+	fmt.Println("Routes:")
+	printRoutes(routes)
+	fmt.Println()
+
+	fmt.Printf("%s\n\n", strings.Join(path, " => "))
+	fmt.Printf("%-4s %-20s %19s %19s %19s %9s\n\n", "HOP", "PAIR", "IN", "OUT", "FEE", "IMPACT")
+
+	// fees of each hop valued in the final symbol at the spot rate of the remaining hops
+	fees := float64(0)
+	for i, h := range hops {
+		fmt.Printf("%-4d %-20s %19f %19f %19f %8.4f%%\n", i+1, h.pair,
+			d.DeroFormatMoneyPrecision(h.in, tokens[h.from].decimals),
+			d.DeroFormatMoneyPrecision(h.out, tokens[h.to].decimals),
+			d.DeroFormatMoneyPrecision(h.fee, tokens[h.to].decimals), h.impact)
+
+		fee := float64(h.fee)
+		for _, next := range hops[i+1:] {
+			fee *= spotRate(next)
+		}
+		fees += fee
+	}
+
+	out := hops[len(hops)-1].out
+	in_float, _ := d.DeroFormatMoneyPrecision(amt, tokens[from].decimals).Float64()
+	out_float, _ := d.DeroFormatMoneyPrecision(out, tokens[to].decimals).Float64()
+	fees_float := fees / math.Pow(10, float64(tokens[to].decimals))
+
+	fmt.Println()
+	fmt.Printf("%f %s == %f %s\n", in_float, from, out_float, to)
+	fmt.Printf("Effective price 1 %s == %0.7f %s\n", from, out_float/in_float, to)
+	fmt.Printf("Total fees %f %s\n", fees_float, to)
+}
+
 func status(words []string) {
 	if len(words) != 1 {
 		fmt.Println("status requires 1 arguments")
@@ -311,12 +393,26 @@ func status(words []string) {
 }
 
 func swap(words []string) {
-	if len(words) != 3 {
-		fmt.Println("swap requires 3 arguments")
+	if len(words) != 3 && len(words) != 4 {
+		fmt.Println("swap requires 3 or 4 arguments")
 		printHelp()
 		return
 	}
 
+	slippage := maxSlippage
+	if len(words) == 4 {
+		var ok bool
+		slippage, ok = parseSlippage(words[3])
+		if !ok {
+			return
+		}
+	}
+
+	if !strings.Contains(words[0], ":") {
+		swapRoute(words[:3], slippage)
+		return
+	}
+
 	getPairs()
 
 	pair := pairs[words[0]]
@@ -332,8 +428,6 @@ func swap(words []string) {
 	}
 
 	symbols := strings.Split(words[0], ":")
-	tokenA := tokens[symbols[0]]
-	tokenB := tokens[symbols[1]]
 
 	if words[2] != symbols[0] && words[2] != symbols[1] {
 		fmt.Printf("symbol %s is not a member of the swap pair %s\n", words[2], words[0])
@@ -342,77 +436,153 @@ func swap(words []string) {
 
 	bal := d.DeroGetSCBal(tokens[words[2]].contract)
 
-	var amt_float float64
-	var err error
+	amt, ok := parseAmount(words[1], tokens[words[2]].decimals, bal)
+	if !ok {
+		return
+	}
 
-	if strings.ToLower(words[1]) == "max" {
-		amt_float = float64(bal) / math.Pow(10, float64(tokens[words[2]].decimals))
-	} else {
-		amt_float, err = strconv.ParseFloat(words[1], 64)
-		if err != nil {
-			fmt.Printf("cannot parse amount '%s'\n", words[2])
+	if amt > bal {
+		fmt.Println("insufficient funds")
+		return
+	}
+
+	to := symbols[0]
+	if words[2] == symbols[0] {
+		to = symbols[1]
+	}
+
+	valIn, _ := poolReserves(words[0], words[2])
+	result := swapOutput(words[0], words[2], amt)
+	slip := ammPriceImpact(amt, valIn)
+
+	fmt.Printf("Swapping %f %s for %f %s fees included (with %f%% slippage)\n",
+		d.DeroFormatMoneyPrecision(amt, tokens[words[2]].decimals), words[2],
+		d.DeroFormatMoneyPrecision(result, tokens[to].decimals), to, slip)
+
+	if slip > maxPriceImpact {
+		fmt.Printf("Slippage > %.0f%%, aborting\n", maxPriceImpact)
+		return
+	}
+
+	minimum := minReceived(result, slippage)
+	fmt.Printf("Minimum received %f %s (max slippage %.2f%%)\n", d.DeroFormatMoneyPrecision(minimum, tokens[to].decimals), to, slippage)
+
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	getPairs()
+
+	fresh := swapOutput(words[0], words[2], amt)
+	if fresh < minimum {
+		fmt.Printf("Reserves moved, swap now returns %f %s which is below the minimum, refusing.\n", d.DeroFormatMoneyPrecision(fresh, tokens[to].decimals), to)
+		fmt.Println("Re-quoting...")
+		swap(words)
+		return
+	}
+
+	txid, b := callSwap(words[0], words[2], amt)
+
+	if !b {
+		fmt.Println("Transaction failed.")
+		return
+	}
+
+	fmt.Printf("Transaction submitted: txid = %s\n", txid)
+}
+
+func swapExact(words []string) {
+	if len(words) != 3 && len(words) != 4 {
+		fmt.Println("swapout requires 3 or 4 arguments")
+		printHelp()
+		return
+	}
+
+	slippage := maxSlippage
+	if len(words) == 4 {
+		var ok bool
+		slippage, ok = parseSlippage(words[3])
+		if !ok {
 			return
 		}
 	}
 
-	if amt_float <= 0.0 {
-		fmt.Println("amount must be > 0.0")
+	getPairs()
+
+	pair := pairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	if pair.val1 == 0 || pair.val2 == 0 {
+		fmt.Println("pair has no liquidity")
 		return
 	}
 
-	amt := uint64(amt_float * math.Pow(10, float64(tokens[words[2]].decimals)))
+	symbols := strings.Split(words[0], ":")
 
-	if amt > bal {
-		fmt.Println("insufficient funds")
+	if words[2] != symbols[0] && words[2] != symbols[1] {
+		fmt.Printf("symbol %s is not a member of the swap pair %s\n", words[2], words[0])
 		return
 	}
 
-	var amt1, amt2 uint64
-	var slip float64
+	to := words[2]
+	from := symbols[0]
+	if to == symbols[0] {
+		from = symbols[1]
+	}
 
-	if words[2] == symbols[0] {
-		amt1 = amt
-		amt2 = 0
-		amt_float := float64(amt) / math.Pow(10, float64(tokenA.decimals))
-		result := float64(amt) * float64(pair.val2) / float64(pair.val1+amt)
-		result = result * float64(10000-pair.fee) / float64(10000)
-		result_float := result / math.Pow(10, float64(tokenB.decimals))
-		slip = 100.0 - (1.0 / (1.0 + float64(amt)/float64(pair.val1)) * 100.0)
-
-		fmt.Printf("Swapping %f %s for %f %s fees included (with %f%% slippage)\n", amt_float, words[2], result_float, symbols[1], slip)
-	} else {
-		amt1 = 0
-		amt2 = amt
-		amt_float := float64(amt) / math.Pow(10, float64(tokenB.decimals))
-		result := float64(amt) * float64(pair.val1) / float64(pair.val2+amt)
-		result = result * float64(10000-pair.fee) / float64(10000)
-		result_float := result / math.Pow(10, float64(tokenA.decimals))
-		slip = 100.0 - (1.0 / (1.0 + float64(amt)/float64(pair.val2)) * 100.0)
+	amt, ok := parseAmount(words[1], tokens[to].decimals, 0)
+	if !ok {
+		return
+	}
 
-		fmt.Printf("Swapping %f %s for %f %s fees included (with %f%% slippage)\n", amt_float, words[2], result_float, symbols[0], slip)
+	required, ok := swapInput(words[0], to, amt)
+	if !ok {
+		fmt.Printf("pair does not hold enough %s\n", to)
+		return
 	}
 
-	if slip > 40.0 {
-		fmt.Println("Slippage > 40%, aborting")
+	bal := d.DeroGetSCBal(tokens[from].contract)
+	if required > bal {
+		fmt.Printf("insufficient funds, requires %f %s\n", d.DeroFormatMoneyPrecision(required, tokens[from].decimals), from)
 		return
 	}
 
+	valIn, _ := poolReserves(words[0], from)
+	slip := ammPriceImpact(required, valIn)
+
+	fmt.Printf("Swapping %f %s for %f %s fees included (with %f%% slippage)\n",
+		d.DeroFormatMoneyPrecision(required, tokens[from].decimals), from,
+		d.DeroFormatMoneyPrecision(amt, tokens[to].decimals), to, slip)
+
+	if slip > maxPriceImpact {
+		fmt.Printf("Slippage > %.0f%%, aborting\n", maxPriceImpact)
+		return
+	}
+
+	minimum := minReceived(amt, slippage)
+	fmt.Printf("Minimum received %f %s (max slippage %.2f%%)\n", d.DeroFormatMoneyPrecision(minimum, tokens[to].decimals), to, slippage)
+
 	if !askContinue() {
 		fmt.Println("aborting...")
 		return
 	}
 
-	var transfers []rpc.Transfer
-	if amt1 > 0 {
-		transfers = d.DeroBuildTransfers(transfers, tokenA.contract, d.DeroGetRandomAddress(), 0, amt1)
-	}
-	if amt2 > 0 {
-		transfers = d.DeroBuildTransfers(transfers, tokenB.contract, d.DeroGetRandomAddress(), 0, amt2)
+	getPairs()
+
+	fresh := swapOutput(words[0], from, required)
+	if fresh < minimum {
+		fmt.Printf("Reserves moved, swap now returns %f %s which is below the minimum, refusing.\n", d.DeroFormatMoneyPrecision(fresh, tokens[to].decimals), to)
+		fmt.Println("Re-quoting...")
+		swapExact(words)
+		return
 	}
-	var args rpc.Arguments
-	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "Swap"})
 
-	txid, b := d.DeroSafeCallSC(pair.contract, transfers, args)
+	txid, b := callSwap(words[0], from, required)
 
 	if !b {
 		fmt.Println("Transaction failed.")
@@ -422,6 +592,15 @@ func swap(words []string) {
 	fmt.Printf("Transaction submitted: txid = %s\n", txid)
 }
 
+func callSwap(key string, from string, amt uint64) (string, bool) {
+	var transfers []rpc.Transfer
+	transfers = d.DeroBuildTransfers(transfers, tokens[from].contract, d.DeroGetRandomAddress(), 0, amt)
+	var args rpc.Arguments
+	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "Swap"})
+
+	return d.DeroSafeCallSC(pairs[key].contract, transfers, args)
+}
+
 func addLiquidity(words []string) {
 	if len(words) != 3 {
 		fmt.Println("addliquidity requires 3 arguments")
@@ -447,22 +626,8 @@ func addLiquidity(words []string) {
 		return
 	}
 
-	var amt_float float64
-	var err error
-
-	if strings.ToLower(words[1]) == "max" {
-		bal := d.DeroGetSCBal(tokens[words[2]].contract)
-		amt_float = float64(bal) / math.Pow(10, float64(tokens[words[2]].decimals))
-	} else {
-		amt_float, err = strconv.ParseFloat(words[1], 64)
-		if err != nil {
-			fmt.Printf("cannot parse amount '%s'\n", words[1])
-			return
-		}
-	}
-
-	if amt_float <= 0.0 {
-		fmt.Println("amount must be > 0.0")
+	amt, ok := parseAmount(words[1], tokens[words[2]].decimals, d.DeroGetSCBal(tokens[words[2]].contract))
+	if !ok {
 		return
 	}
 
@@ -470,45 +635,32 @@ func addLiquidity(words []string) {
 	outstanding, _ := strconv.Atoi(outstanding_str)
 
 	var amt1, amt2 uint64
-	var float1, float2 float64
 
 	if words[2] == symbols[0] {
-		float1 = amt_float
-		amt1 = uint64(amt_float * math.Pow(10, float64(tok1.decimals)))
+		amt1 = amt
 		if outstanding == 0 {
-			fmt.Printf("Providing initial liquidity to pair %s with %f %s\n", words[0], amt_float, symbols[0])
+			fmt.Printf("Providing initial liquidity to pair %s with %f %s\n", words[0], d.DeroFormatMoneyPrecision(amt, tok1.decimals), symbols[0])
 			ans := promptInput("Enter equal value of " + symbols[1] + ": ")
 
-			var err error
-			float2, err = strconv.ParseFloat(ans, 64)
-			if err != nil {
-				fmt.Printf("cannot parse amount '%s'\n", ans)
+			amt2, ok = parseAmount(ans, tok2.decimals, 0)
+			if !ok {
 				return
 			}
-			amt2 = uint64(float2 * math.Pow(10, float64(tok2.decimals)))
 		} else {
-			amt2 = multDiv(amt1, pair.val2, pair.val1)
-			float2_big := d.DeroFormatMoneyPrecision(amt2, tok2.decimals)
-			float2, _ = float2_big.Float64()
+			amt2 = ammLiquidityPair(amt1, pair.val1, pair.val2)
 		}
 	} else {
-		float2 = amt_float
-		amt2 = uint64(amt_float * math.Pow(10, float64(tok2.decimals)))
+		amt2 = amt
 		if outstanding == 0 {
-			fmt.Printf("Providing initial liquidity to pair %s with %f %s\n", words[0], amt_float, symbols[1])
+			fmt.Printf("Providing initial liquidity to pair %s with %f %s\n", words[0], d.DeroFormatMoneyPrecision(amt, tok2.decimals), symbols[1])
 			ans := promptInput("Enter equal value of " + symbols[0] + ": ")
 
-			var err error
-			float1, err = strconv.ParseFloat(ans, 64)
-			if err != nil {
-				fmt.Printf("cannot parse amount '%s'\n", ans)
+			amt1, ok = parseAmount(ans, tok1.decimals, 0)
+			if !ok {
 				return
 			}
-			amt1 = uint64(float1 * math.Pow(10, float64(tok1.decimals)))
 		} else {
-			amt1 = multDiv(amt2, pair.val1, pair.val2)
-			float1_big := d.DeroFormatMoneyPrecision(amt1, tok1.decimals)
-			float1, _ = float1_big.Float64()
+			amt1 = ammLiquidityPair(amt2, pair.val2, pair.val1)
 		}
 	}
 
@@ -524,31 +676,44 @@ func addLiquidity(words []string) {
 		return
 	}
 
-	fmt.Printf("Adding liquidity to pair %s: %f %s, %f %s\n", words[0], float1, symbols[0], float2, symbols[1])
+	shares := ammMintShares(amt1, amt2, pair.val1, pair.val2, uint64(outstanding))
+
+	fmt.Printf("Adding liquidity to pair %s: %f %s, %f %s\n", words[0],
+		d.DeroFormatMoneyPrecision(amt1, tok1.decimals), symbols[0],
+		d.DeroFormatMoneyPrecision(amt2, tok2.decimals), symbols[1])
+	fmt.Printf("Shares minted %d of %d outstanding\n", shares, uint64(outstanding)+shares)
 	if !askContinue() {
 		fmt.Println("aborting...")
 		return
 	}
 
-	var transfers []rpc.Transfer
-	transfers = d.DeroBuildTransfers(transfers, tok1.contract, d.DeroGetRandomAddress(), 0, amt1)
-	transfers = d.DeroBuildTransfers(transfers, tok2.contract, d.DeroGetRandomAddress(), 0, amt2)
-	var args rpc.Arguments
-	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "AddLiquidity"})
-
-	txid, b := d.DeroSafeCallSC(pair.contract, transfers, args)
+	txid, b := callAddLiquidity(words[0], amt1, amt2)
 
 	if !b {
 		fmt.Println("Transaction failed.")
 		return
 	}
 
+	recordLiquidity(words[0], "add", amt1, amt2, shares, txid)
+
 	fmt.Printf("Transaction submitted: txid = %s\n", txid)
 }
 
+func callAddLiquidity(key string, amt1 uint64, amt2 uint64) (string, bool) {
+	symbols := strings.Split(key, ":")
+
+	var transfers []rpc.Transfer
+	transfers = d.DeroBuildTransfers(transfers, tokens[symbols[0]].contract, d.DeroGetRandomAddress(), 0, amt1)
+	transfers = d.DeroBuildTransfers(transfers, tokens[symbols[1]].contract, d.DeroGetRandomAddress(), 0, amt2)
+	var args rpc.Arguments
+	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "AddLiquidity"})
+
+	return d.DeroSafeCallSC(pairs[key].contract, transfers, args)
+}
+
 func remLiquidity(words []string) {
-	if len(words) != 2 {
-		fmt.Println("remliquidity requires 2 arguments")
+	if len(words) != 2 && len(words) != 3 {
+		fmt.Println("remliquidity requires 2 or 3 arguments")
 		printHelp()
 		return
 	}
@@ -562,13 +727,14 @@ func remLiquidity(words []string) {
 		return
 	}
 
-	percent, err := strconv.ParseFloat(words[1], 64)
+	// percentage in basis points
+	bps, err := d.DeroStringToAmount(words[1], 2)
 	if err != nil {
-		fmt.Printf("cannot parse percentage '%s'\n", words[2])
+		fmt.Printf("cannot parse percentage '%s'\n", words[1])
 		return
 	}
 
-	if percent <= 0.0 || percent > 100.0 {
+	if bps == 0 || bps > 10000 {
 		fmt.Println("amount must be > 0.0 and <= 100.0")
 		return
 	}
@@ -583,36 +749,50 @@ func remLiquidity(words []string) {
 	tokenA := tokens[symbols[0]]
 	tokenB := tokens[symbols[1]]
 
-	bal1_uint64 := multDiv(pair.val1, myShares, pair.sharesOutstanding)
-	bal2_uint64 := multDiv(pair.val2, myShares, pair.sharesOutstanding)
+	if len(words) == 3 && words[2] != symbols[0] && words[2] != symbols[1] {
+		fmt.Printf("symbol %s is not a member of the swap pair %s\n", words[2], words[0])
+		return
+	}
 
-	bal1_float := float64(bal1_uint64) / math.Pow(10, float64(tokenA.decimals))
-	bal2_float := float64(bal2_uint64) / math.Pow(10, float64(tokenB.decimals))
+	bal1, bal2 := ammBurnShares(myShares, pair.val1, pair.val2, pair.sharesOutstanding)
 
-	rem1_float := bal1_float * percent / 100.0
-	rem2_float := bal2_float * percent / 100.0
+	remShares := multDiv(myShares, bps, 10000)
+	rem1, rem2 := ammBurnShares(remShares, pair.val1, pair.val2, pair.sharesOutstanding)
 
-	remShares := uint64(float64(myShares) * percent / 100.0)
+	fmt.Printf("Your liquidity for pair %s is %f %s, %f %s\n", words[0],
+		d.DeroFormatMoneyPrecision(bal1, tokenA.decimals), symbols[0],
+		d.DeroFormatMoneyPrecision(bal2, tokenB.decimals), symbols[1])
+	fmt.Printf("Remove %f%% (%f %s, %f %s)\n", d.DeroFormatMoneyPrecision(bps, 2),
+		d.DeroFormatMoneyPrecision(rem1, tokenA.decimals), symbols[0],
+		d.DeroFormatMoneyPrecision(rem2, tokenB.decimals), symbols[1])
 
-	fmt.Printf("Your liquidity for pair %s is %f %s, %f %s\n", words[0], bal1_float, symbols[0], bal2_float, symbols[1])
-	fmt.Printf("Remove %f%% (%f %s, %f %s)\n", percent, rem1_float, symbols[0], rem2_float, symbols[1])
+	if len(words) == 3 {
+		remLiquiditySingle(words[0], words[2], remShares, rem1, rem2)
+		return
+	}
 
 	if !askContinue() {
 		fmt.Println("aborting...")
 		return
 	}
 
-	var transfers []rpc.Transfer
-	transfers = d.DeroBuildTransfers(transfers, pair.contract, d.DeroGetRandomAddress(), 0, remShares)
-	var args rpc.Arguments
-	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "RemoveLiquidity"})
-
-	txid, b := d.DeroSafeCallSC(pair.contract, transfers, args)
+	txid, b := callRemoveLiquidity(words[0], remShares)
 
 	if !b {
 		fmt.Println("Transaction failed.")
 		return
 	}
 
+	recordLiquidity(words[0], "remove", rem1, rem2, remShares, txid)
+
 	fmt.Printf("Transaction submitted: txid = %s\n", txid)
 }
+
+func callRemoveLiquidity(key string, shares uint64) (string, bool) {
+	var transfers []rpc.Transfer
+	transfers = d.DeroBuildTransfers(transfers, pairs[key].contract, d.DeroGetRandomAddress(), 0, shares)
+	var args rpc.Arguments
+	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "RemoveLiquidity"})
+
+	return d.DeroSafeCallSC(pairs[key].contract, transfers, args)
+}
@@ -11,8 +11,12 @@ import (
 
 	d "github.com/deroholic/derogo"
 	"github.com/deroproject/derohe/rpc"
+	"github.com/holiman/uint256"
 )
 
+// prices on the order book are fixed point with 7 decimals
+const priceScale = 10000000
+
 type Hist struct {
 	timestamp uint64
 	v1        uint64
@@ -42,7 +46,13 @@ type TradePair struct {
 var tradePairs map[string]TradePair
 
 func getTradePairs() {
-	tradePairs = make(map[string]TradePair)
+	tradePairs = fetchTradePairs()
+}
+
+// fetchTradePairs reads every trade pair without touching tradePairs, for
+// use from background tasks.
+func fetchTradePairs() map[string]TradePair {
+	tradePairs := make(map[string]TradePair)
 	tradeVars, tradeValid := d.DeroGetVars(swapRegistry)
 
 	if tradeValid {
@@ -127,6 +137,8 @@ func getTradePairs() {
 			}
 		}
 	}
+
+	return tradePairs
 }
 
 func tradeSell(words []string) {
@@ -166,36 +178,12 @@ func tradeSell(words []string) {
 	}
 
 	amt_64 := uint64(amt_float * math.Pow10(tokenA.decimals))
-	price_64 := uint64(price_float * 10000000.0)
-
-	var transfers []rpc.Transfer
-	transfers = d.DeroBuildTransfers(transfers, tokenA.contract, d.DeroGetRandomAddress(), 0, amt_64)
-
-	var args rpc.Arguments
-	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "Sell"})
-	args = append(args, rpc.Argument{"price", rpc.DataUint64, price_64})
-
-	ge, ge_valid := d.DeroEstimateGas(pair.contract, transfers, args, 0)
-	if !ge_valid || ge.Status != "OK" {
-		fmt.Printf("Error: %+s\n", ge.Status)
-		return
-	}
-
-	fmt.Printf("Sell limit order %f %s @ %f %s\n", amt_float, symbols[0], price_float, symbols[1])
-	if !askContinue() {
-		fmt.Println("aborting...")
-		return
-	}
-
-	txid, b := d.DeroSafeCallSC(pair.contract, transfers, args)
-	//      txid, b := d.DeroCallSC(pair.contract, transfers, args, 300)
+	price_64 := uint64(price_float * priceScale)
 
-	if !b {
-		fmt.Println("Transaction failed.")
-		return
-	}
+	previewLimit(pair, symbols, "sell", amt_64, price_64)
 
-	fmt.Printf("Transaction submitted: txid = %s, fees = %d\n", txid, ge.GasStorage)
+	transfers, args := sellOrder(words[0], amt_64, price_64)
+	submitOrder(pair.contract, transfers, args, fmt.Sprintf("Sell limit order %f %s @ %f %s", amt_float, symbols[0], price_float, symbols[1]))
 }
 
 func tradeBuy(words []string) {
@@ -216,7 +204,6 @@ func tradeBuy(words []string) {
 
 	symbols := strings.Split(words[0], ":")
 	tokenA := tokens[symbols[0]]
-	tokenB := tokens[symbols[1]]
 
 	amt_float, err := strconv.ParseFloat(words[1], 64)
 	if err != nil {
@@ -236,38 +223,106 @@ func tradeBuy(words []string) {
 	}
 
 	amt1_64 := uint64(amt_float * math.Pow10(tokenA.decimals))
-	price_64 := uint64(price_float * 10000000.0)
-	amt2_64 := uint64(amt_float*price_float*math.Pow10(tokenB.decimals)) + 1
+	price_64 := uint64(price_float * priceScale)
+
+	previewLimit(pair, symbols, "buy", amt1_64, price_64)
+
+	transfers, args := buyOrder(words[0], amt1_64, price_64)
+	submitOrder(pair.contract, transfers, args, fmt.Sprintf("Buy limit order %f %s @ %f %s", amt_float, symbols[0], price_float, symbols[1]))
+}
+
+// sendOrder submits without asking, for orders placed by background tasks.
+func sendOrder(contract string, transfers []rpc.Transfer, args rpc.Arguments) (string, bool) {
+	ge, ge_valid := d.DeroEstimateGas(contract, transfers, args, 0)
+	if !ge_valid || ge.Status != "OK" {
+		notify("Error: %+s\n", ge.Status)
+		return "", false
+	}
+
+	txid, b := d.DeroSafeCallSC(contract, transfers, args)
+	if !b {
+		notify("Transaction failed.\n")
+		return "", false
+	}
+
+	return txid, true
+}
+
+// sellOrder builds a Sell of amt_64 of the first symbol at price_64.
+func sellOrder(key string, amt_64 uint64, price_64 uint64) ([]rpc.Transfer, rpc.Arguments) {
+	symbols := strings.Split(key, ":")
 
 	var transfers []rpc.Transfer
-	transfers = d.DeroBuildTransfers(transfers, tokenB.contract, d.DeroGetRandomAddress(), 0, amt2_64)
+	transfers = d.DeroBuildTransfers(transfers, getToken(symbols[0]).contract, d.DeroGetRandomAddress(), 0, amt_64)
+
+	var args rpc.Arguments
+	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "Sell"})
+	args = append(args, rpc.Argument{"price", rpc.DataUint64, price_64})
+
+	return transfers, args
+}
+
+// buyOrder builds a Buy of amt1_64 of the first symbol at price_64, paying
+// with enough of the second symbol to cover it.
+func buyOrder(key string, amt1_64 uint64, price_64 uint64) ([]rpc.Transfer, rpc.Arguments) {
+	symbols := strings.Split(key, ":")
+	amt2_64 := orderCost(amt1_64, price_64, getToken(symbols[0]).decimals, getToken(symbols[1]).decimals) + 1
+
+	var transfers []rpc.Transfer
+	transfers = d.DeroBuildTransfers(transfers, getToken(symbols[1]).contract, d.DeroGetRandomAddress(), 0, amt2_64)
 
 	var args rpc.Arguments
 	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "Buy"})
 	args = append(args, rpc.Argument{"o1", rpc.DataUint64, amt1_64})
 	args = append(args, rpc.Argument{"price", rpc.DataUint64, price_64})
 
-	ge, ge_valid := d.DeroEstimateGas(pair.contract, transfers, args, 0)
+	return transfers, args
+}
+
+// cancelOrder builds a Cancel of order tx.
+func cancelOrder(tx uint64) ([]rpc.Transfer, rpc.Arguments) {
+	var transfers []rpc.Transfer
+	var args rpc.Arguments
+	args = append(args, rpc.Argument{"entrypoint", rpc.DataString, "Cancel"})
+	args = append(args, rpc.Argument{"tx", rpc.DataUint64, tx})
+
+	return transfers, args
+}
+
+// orderCost is the value of amt1 of the first symbol at price in the second symbol.
+func orderCost(amt1 uint64, price uint64, dec1 int, dec2 int) uint64 {
+	cost := new(uint256.Int).Mul(uint256.NewInt(amt1), uint256.NewInt(price))
+	cost.Mul(cost, new(uint256.Int).Exp(uint256.NewInt(10), uint256.NewInt(uint64(dec2))))
+	cost.Div(cost, new(uint256.Int).Exp(uint256.NewInt(10), uint256.NewInt(uint64(dec1))))
+	cost.Div(cost, uint256.NewInt(priceScale))
+
+	return cost.Uint64()
+}
+
+// submitOrder estimates gas, asks for confirmation of preview and submits.
+func submitOrder(contract string, transfers []rpc.Transfer, args rpc.Arguments, preview string) (string, bool) {
+	ge, ge_valid := d.DeroEstimateGas(contract, transfers, args, 0)
 	if !ge_valid || ge.Status != "OK" {
 		fmt.Printf("Error: %+s\n", ge.Status)
-		return
+		return "", false
 	}
 
-	fmt.Printf("Buy limit order %f %s @ %f %s\n", amt_float, symbols[0], price_float, symbols[1])
+	fmt.Println(preview)
 	if !askContinue() {
 		fmt.Println("aborting...")
-		return
+		return "", false
 	}
 
-	txid, b := d.DeroSafeCallSC(pair.contract, transfers, args)
-	//      txid, b := d.DeroCallSC(pair.contract, transfers, args, 300)
+	txid, b := d.DeroSafeCallSC(contract, transfers, args)
+	//      txid, b := d.DeroCallSC(contract, transfers, args, 300)
 
 	if !b {
 		fmt.Println("Transaction failed.")
-		return
+		return "", false
 	}
 
 	fmt.Printf("Transaction submitted: txid = %s, fees = %d\n", txid, ge.GasStorage)
+	return txid, true
 }
 
 func tradeCancel(words []string) {
@@ -325,6 +380,14 @@ func tradeCancel(words []string) {
 	}
 
 	fmt.Printf("Transaction submitted: txid = %s, fees = %d\n", txid, ge.GasStorage)
+
+	if tx > 0 {
+		markCancelled(words[0], uint64(tx))
+	} else {
+		for k := range myOrders(pair) {
+			markCancelled(words[0], k)
+		}
+	}
 }
 
 type ordSum struct {
@@ -424,6 +487,9 @@ func tradeBook(words []string) {
 				float64(buy[i].total)/math.Pow10(tokens[symbols[0]].decimals))
 		}
 	}
+
+	fmt.Printf("\n")
+	printBookMetrics(pair, symbols)
 }
 
 func tradeOrders(words []string) {
@@ -444,6 +510,8 @@ func tradeOrders(words []string) {
 
 	symbols := strings.Split(words[0], ":")
 
+	recordFills(tradePairs)
+
 	pubKey := hex.EncodeToString(d.DeroGetPub())
 
 	keys := make([]uint64, 0, len(pair.orders))
@@ -465,6 +533,20 @@ func tradeOrders(words []string) {
 	}
 }
 
+// myOrders returns the open orders of pair placed by this wallet.
+func myOrders(pair TradePair) map[uint64]Order {
+	pubKey := hex.EncodeToString(d.DeroGetPub())
+
+	mine := make(map[uint64]Order)
+	for k, o := range pair.orders {
+		if o.s == pubKey {
+			mine[k] = o
+		}
+	}
+
+	return mine
+}
+
 func tradeHistory(words []string) {
 	if len(words) != 1 {
 		fmt.Println("history requires 1 arguments")
@@ -501,8 +583,23 @@ func tradeHistory(words []string) {
 func tradeHelp() {
 	fmt.Println("trade buy <pair> <amount> <price>")
 	fmt.Println("trade sell <pair> <amount> <price>")
+	fmt.Println("trade buy-market <pair> <amount>")
+	fmt.Println("trade sell-market <pair> <amount>")
 	fmt.Println("trade cancel <pair> [<orderId> | all]")
+	fmt.Println("trade amend <pair> <orderId> [price=<p>] [amount=<a>]")
+	fmt.Println("trade stop <pair> <amount> <trigger> [<limit>]")
+	fmt.Println("trade takeprofit <pair> <amount> <trigger> [<limit>]")
+	fmt.Println("trade conditions [cancel <id>]")
+	fmt.Println("trade twap <pair> [buy | sell] <amount> <duration> <slices>")
+	fmt.Println("trade iceberg <pair> [buy | sell] <amount> <price> <visible>")
+	fmt.Println("trade parents")
+	fmt.Println("trade cancel-parent <id>")
+	fmt.Println("trade grid <pair> <low> <high> <levels> <amount>")
+	fmt.Println("trade grid [status | profit | stop <id>]")
 	fmt.Println("trade history <pair>")
+	fmt.Println("trade chart <pair> <interval> [export <file>]")
 	fmt.Println("trade orders <pair>")
+	fmt.Println("trade fills <pair>")
 	fmt.Println("trade book <pair>")
+	fmt.Println("trade depth <pair>")
 }
@@ -25,11 +25,22 @@ func printHelp() {
 	fmt.Println("transfer <token> <dero_wallet> <amount>")
 	fmt.Println("balance")
 	fmt.Println("pairs")
+	fmt.Println("positions")
 	fmt.Println("addliquidity <pair> [<amount> | max] <symbol>")
-	fmt.Println("remliquidity <pair> <percent>")
-	fmt.Println("swap <pair> [<amount> | max] <symbol>")
+	fmt.Println("remliquidity <pair> <percent> [<symbol>]")
+	fmt.Println("zap <pair> [<amount> | max] <symbol>")
+	fmt.Println("swap <pair> [<amount> | max] <symbol> [<max_slippage>]")
+	fmt.Println("swap <symbol1> [<amount> | max] <symbol2> [<max_slippage>]")
+	fmt.Println("swapout <pair> <amount> <symbol> [<max_slippage>]")
+	fmt.Println("swapsplit <symbol1> [<amount> | max] <symbol2> [<max_slippage>]")
 	fmt.Println("status <pair>")
-	fmt.Println("quote <symbol1> <symbol2>")
+	fmt.Println("poolstats [<column>] [<hours>]")
+	fmt.Println("createpair <symbol1>:<symbol2>")
+	fmt.Println("createtradepair <symbol1>:<symbol2>")
+	fmt.Println("quote <symbol1> [<amount>] <symbol2>")
+	fmt.Println("arb [execute <pair>]")
+	fmt.Println("arb cycles [execute <n>]")
+	fmt.Println("set [slippage <percent> | hops <n> | routes <n> | band <percent> | watch [on | off] | watch interval <seconds>]")
 }
 
 var completer = readline.NewPrefixCompleter(
@@ -47,19 +58,70 @@ var completer = readline.NewPrefixCompleter(
 	readline.PcItem("transfer"),
 	readline.PcItem("bridge"),
 	readline.PcItem("pairs"),
+	readline.PcItem("positions"),
 	readline.PcItem("addliquidity"),
 	readline.PcItem("remliquidity"),
+	readline.PcItem("zap"),
 	readline.PcItem("swap"),
+	readline.PcItem("swapout"),
+	readline.PcItem("swapsplit"),
 	readline.PcItem("status"),
+	readline.PcItem("poolstats",
+		readline.PcItem("pair"),
+		readline.PcItem("swaps"),
+		readline.PcItem("adds"),
+		readline.PcItem("rems"),
+		readline.PcItem("volume"),
+		readline.PcItem("fees"),
+		readline.PcItem("liquidity"),
+		readline.PcItem("apr"),
+	),
 	readline.PcItem("quote"),
+	readline.PcItem("arb",
+		readline.PcItem("execute"),
+		readline.PcItem("cycles",
+			readline.PcItem("execute"),
+		),
+	),
+	readline.PcItem("createpair"),
+	readline.PcItem("createtradepair"),
+	readline.PcItem("set",
+		readline.PcItem("slippage"),
+		readline.PcItem("hops"),
+		readline.PcItem("routes"),
+		readline.PcItem("band"),
+		readline.PcItem("watch",
+			readline.PcItem("on"),
+			readline.PcItem("off"),
+			readline.PcItem("interval"),
+		),
+	),
 	readline.PcItem("trade",
 		readline.PcItem("help"),
 		readline.PcItem("buy"),
 		readline.PcItem("sell"),
+		readline.PcItem("buy-market"),
+		readline.PcItem("sell-market"),
 		readline.PcItem("cancel"),
+		readline.PcItem("amend"),
+		readline.PcItem("stop"),
+		readline.PcItem("takeprofit"),
+		readline.PcItem("conditions"),
+		readline.PcItem("twap"),
+		readline.PcItem("iceberg"),
+		readline.PcItem("parents"),
+		readline.PcItem("cancel-parent"),
+		readline.PcItem("grid",
+			readline.PcItem("status"),
+			readline.PcItem("stop"),
+			readline.PcItem("profit"),
+		),
 		readline.PcItem("history"),
+		readline.PcItem("chart"),
 		readline.PcItem("orders"),
+		readline.PcItem("fills"),
 		readline.PcItem("book"),
+		readline.PcItem("depth"),
 	),
 )
 
@@ -86,6 +148,11 @@ func promptInput(prompt string) (string) {
 	return str
 }
 
+// notify prints a message from a background task without breaking the prompt.
+func notify(format string, a ...interface{}) {
+	fmt.Fprintf(l.Stdout(), format, a...)
+}
+
 func askContinue() bool {
 	str := promptInput("Continue (N/y) ? ")
 
@@ -101,6 +168,8 @@ func askContinue() bool {
 
 func commandLoop() {
 	go update_prompt()
+	go orderEngine()
+	go orderWatcher()
 
 	for {
 		line, err := l.Readline()
@@ -142,16 +211,34 @@ func commandLoop() {
 				displayTokens()
 			case "pairs":
 				displayPairs()
+			case "positions":
+				displayPositions()
 			case "addliquidity":
 				addLiquidity(words[1:])
 			case "remliquidity":
 				remLiquidity(words[1:])
+			case "zap":
+				zap(words[1:])
 			case "swap":
 				swap(words[1:])
+			case "swapout":
+				swapExact(words[1:])
+			case "swapsplit":
+				swapSplit(words[1:])
 			case "status":
 				status(words[1:])
+			case "poolstats":
+				poolStats(words[1:])
+			case "createpair":
+				createPair(words[1:])
+			case "createtradepair":
+				createTradePair(words[1:])
 			case "quote":
 				quote(words[1:])
+			case "set":
+				setOption(words[1:])
+			case "arb":
+				arb(words[1:])
 			case "trade":
 				if len(words) > 1 {
 					switch words[1] + "" {
@@ -161,14 +248,40 @@ func commandLoop() {
 						tradeBuy(words[2:])
 					case "sell":
 						tradeSell(words[2:])
+					case "buy-market":
+						tradeMarket(words[2:], "buy")
+					case "sell-market":
+						tradeMarket(words[2:], "sell")
 					case "cancel":
 						tradeCancel(words[2:])
+					case "amend":
+						tradeAmend(words[2:])
+					case "stop", "takeprofit":
+						tradeCondition(words[2:], words[1])
+					case "conditions":
+						tradeConditions(words[2:])
+					case "twap":
+						tradeTwap(words[2:])
+					case "iceberg":
+						tradeIceberg(words[2:])
+					case "parents":
+						tradeParents(words[2:])
+					case "cancel-parent":
+						tradeCancelParent(words[2:])
+					case "grid":
+						tradeGrid(words[2:])
+					case "chart":
+						tradeChart(words[2:])
 					case "history":
 						tradeHistory(words[2:])
 					case "orders":
 						tradeOrders(words[2:])
 					case "book":
 						tradeBook(words[2:])
+					case "depth":
+						tradeDepth(words[2:])
+					case "fills":
+						tradeFills(words[2:])
 					}
 				}
 			case "exit", "quit", "q", "bye":
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the order watcher is on by default, changed with set watch
+var watchEnabled = true
+var watchInterval = 15 * time.Second
+var watch_mutex sync.Mutex
+
+// order events wait here for the watcher, whoever took the snapshot
+var watchEvents []orderEvent
+
+func watchSettings() (bool, time.Duration) {
+	watch_mutex.Lock()
+	defer watch_mutex.Unlock()
+
+	return watchEnabled, watchInterval
+}
+
+// queueEvents hands order events to the watcher.
+func queueEvents(events []orderEvent) {
+	watch_mutex.Lock()
+	defer watch_mutex.Unlock()
+
+	watchEvents = append(watchEvents, events...)
+}
+
+func takeEvents() (events []orderEvent) {
+	watch_mutex.Lock()
+	defer watch_mutex.Unlock()
+
+	events, watchEvents = watchEvents, nil
+	return
+}
+
+// orderWatcher runs alongside update_prompt, snapshotting the wallet's
+// orders every watch interval and printing a notice for every change. With
+// the watcher off fills are still recorded whenever the order engine or a
+// trade command reads the books, but nothing is announced.
+func orderWatcher() {
+	var polled time.Time
+
+	for {
+		time.Sleep(time.Second)
+
+		enabled, interval := watchSettings()
+		if !enabled {
+			takeEvents()
+			continue
+		}
+		if time.Since(polled) < interval {
+			continue
+		}
+
+		recordFills(fetchTradePairs())
+		polled = time.Now()
+
+		events := takeEvents()
+		for _, e := range events {
+			symbols := strings.Split(e.pair, ":")
+
+			switch {
+			case e.cancelled:
+				notify("order %d on %s cancelled, %.0f%% filled\n", e.fill.Order, e.pair, e.filled)
+			case e.unknown:
+				notify("order %d on %s left the book without a confirmed fill, %.0f%% filled\n", e.fill.Order, e.pair, e.filled)
+			case e.closed:
+				notify("order %d on %s filled (%s %f %s @ %f %s)\n", e.fill.Order, e.pair, e.fill.Side,
+					float64(e.fill.Amount)/math.Pow10(getToken(symbols[0]).decimals), symbols[0],
+					float64(e.fill.Price)/priceScale, symbols[1])
+			default:
+				notify("order %d on %s %.0f%% filled (%s %f %s @ %f %s)\n", e.fill.Order, e.pair, e.filled, e.fill.Side,
+					float64(e.fill.Amount)/math.Pow10(getToken(symbols[0]).decimals), symbols[0],
+					float64(e.fill.Price)/priceScale, symbols[1])
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	d "github.com/deroholic/derogo"
+)
+
+// orderPair puts amounts of the from and other symbols into pair order.
+func orderPair(key string, from string, amtFrom uint64, amtOther uint64) (uint64, uint64) {
+	if from == strings.Split(key, ":")[0] {
+		return amtFrom, amtOther
+	}
+	return amtOther, amtFrom
+}
+
+func zap(words []string) {
+	if len(words) != 3 {
+		fmt.Println("zap requires 3 arguments")
+		printHelp()
+		return
+	}
+
+	getPairs()
+
+	pair := pairs[words[0]]
+
+	if len(pair.contract) == 0 {
+		fmt.Printf("pair '%s' is not registered\n", words[0])
+		return
+	}
+
+	if pair.sharesOutstanding == 0 || pair.val1 == 0 || pair.val2 == 0 {
+		fmt.Println("pair has no liquidity")
+		return
+	}
+
+	symbols := strings.Split(words[0], ":")
+
+	if words[2] != symbols[0] && words[2] != symbols[1] {
+		fmt.Printf("symbol %s is not a member of the swap pair %s\n", words[2], words[0])
+		return
+	}
+
+	from := words[2]
+	other := symbols[0]
+	if from == symbols[0] {
+		other = symbols[1]
+	}
+
+	bal := d.DeroGetSCBal(tokens[from].contract)
+
+	amt, ok := parseAmount(words[1], tokens[from].decimals, bal)
+	if !ok {
+		return
+	}
+
+	if amt > bal {
+		fmt.Println("insufficient funds")
+		return
+	}
+
+	valIn, valOut := poolReserves(words[0], from)
+	part := ammZapSwap(amt, valIn, valOut, pair.fee)
+	out := ammAmountOut(part, valIn, valOut, pair.fee)
+
+	if part == 0 || out == 0 {
+		fmt.Println("amount too small to zap")
+		return
+	}
+
+	// deposit against the reserves left by the swap
+	postIn, postOut := valIn+part, valOut-out
+	deposit := ammLiquidityPair(out, postOut, postIn)
+	if deposit > amt-part {
+		deposit = amt - part
+	}
+
+	amt1, amt2 := orderPair(words[0], from, deposit, out)
+	val1, val2 := orderPair(words[0], from, postIn, postOut)
+	shares := ammMintShares(amt1, amt2, val1, val2, pair.sharesOutstanding)
+	slip := ammPriceImpact(part, valIn)
+
+	fmt.Printf("Zap %f %s into pair %s\n", d.DeroFormatMoneyPrecision(amt, tokens[from].decimals), from, words[0])
+	fmt.Printf("  swap %f %s for %f %s fees included (with %f%% slippage)\n",
+		d.DeroFormatMoneyPrecision(part, tokens[from].decimals), from,
+		d.DeroFormatMoneyPrecision(out, tokens[other].decimals), other, slip)
+	fmt.Printf("  add liquidity %f %s, %f %s\n",
+		d.DeroFormatMoneyPrecision(deposit, tokens[from].decimals), from,
+		d.DeroFormatMoneyPrecision(out, tokens[other].decimals), other)
+	fmt.Printf("Shares minted %d of %d outstanding\n", shares, pair.sharesOutstanding+shares)
+	fmt.Printf("Dust left %f %s\n", d.DeroFormatMoneyPrecision(amt-part-deposit, tokens[from].decimals), from)
+
+	if slip > maxPriceImpact {
+		fmt.Printf("Slippage > %.0f%%, aborting\n", maxPriceImpact)
+		return
+	}
+
+	minimum := minReceived(out, maxSlippage)
+
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	getPairs()
+
+	if swapOutput(words[0], from, part) < minimum {
+		fmt.Println("Reserves moved past the slippage tolerance, refusing.")
+		fmt.Println("Re-quoting...")
+		zap(words)
+		return
+	}
+
+	before := d.DeroGetSCBal(tokens[other].contract)
+
+	txid, b := callSwap(words[0], from, part)
+	if !b {
+		fmt.Println("Transaction failed.")
+		return
+	}
+
+	fmt.Printf("Swap submitted: txid = %s\n", txid)
+	fmt.Printf("Waiting for %s to arrive...\n", other)
+
+	after, arrived := waitForBalance(tokens[other].contract, before)
+	if !arrived {
+		fmt.Println("Timed out waiting for the swap, liquidity not added")
+		return
+	}
+
+	received := after - before
+	left := amt - part
+
+	// size the deposit on the reserves the swap actually left behind
+	getPairs()
+	valIn, valOut = poolReserves(words[0], from)
+
+	depFrom := ammLiquidityPair(received, valOut, valIn)
+	depOther := received
+	if depFrom > left {
+		depFrom = left
+		depOther = ammLiquidityPair(left, valIn, valOut)
+	}
+
+	amt1, amt2 = orderPair(words[0], from, depFrom, depOther)
+
+	txid, b = callAddLiquidity(words[0], amt1, amt2)
+	if !b {
+		fmt.Printf("Add liquidity failed, holding %f %s and %f %s\n",
+			d.DeroFormatMoneyPrecision(left, tokens[from].decimals), from,
+			d.DeroFormatMoneyPrecision(received, tokens[other].decimals), other)
+		return
+	}
+
+	recordLiquidity(words[0], "add", amt1, amt2, ammMintShares(amt1, amt2, pairs[words[0]].val1, pairs[words[0]].val2, pairs[words[0]].sharesOutstanding), txid)
+
+	fmt.Printf("Add liquidity submitted: txid = %s\n", txid)
+	fmt.Printf("Dust left %f %s, %f %s\n",
+		d.DeroFormatMoneyPrecision(left-depFrom, tokens[from].decimals), from,
+		d.DeroFormatMoneyPrecision(received-depOther, tokens[other].decimals), other)
+}
+
+// remLiquiditySingle removes remShares of pair key and swaps the other side
+// into symbol to through the same pair.
+func remLiquiditySingle(key string, to string, remShares uint64, rem1 uint64, rem2 uint64) {
+	pair := pairs[key]
+	symbols := strings.Split(key, ":")
+
+	other := symbols[0]
+	remTo, remOther := rem2, rem1
+	if to == symbols[0] {
+		other = symbols[1]
+		remTo, remOther = rem1, rem2
+	}
+
+	// swap against the reserves left by the removal
+	valIn, valOut := poolReserves(key, other)
+	postIn, postOut := valIn-remOther, valOut-remTo
+	out := ammAmountOut(remOther, postIn, postOut, pair.fee)
+	slip := ammPriceImpact(remOther, postIn)
+
+	fmt.Printf("  swap %f %s for %f %s fees included (with %f%% slippage)\n",
+		d.DeroFormatMoneyPrecision(remOther, tokens[other].decimals), other,
+		d.DeroFormatMoneyPrecision(out, tokens[to].decimals), to, slip)
+	fmt.Printf("Receive %f %s\n", d.DeroFormatMoneyPrecision(remTo+out, tokens[to].decimals), to)
+
+	if slip > maxPriceImpact {
+		fmt.Printf("Slippage > %.0f%%, aborting\n", maxPriceImpact)
+		return
+	}
+
+	if !askContinue() {
+		fmt.Println("aborting...")
+		return
+	}
+
+	before := d.DeroGetSCBal(tokens[other].contract)
+
+	txid, b := callRemoveLiquidity(key, remShares)
+	if !b {
+		fmt.Println("Transaction failed.")
+		return
+	}
+
+	recordLiquidity(key, "remove", rem1, rem2, remShares, txid)
+
+	fmt.Printf("Remove liquidity submitted: txid = %s\n", txid)
+	fmt.Printf("Waiting for %s to arrive...\n", other)
+
+	after, arrived := waitForBalance(tokens[other].contract, before)
+	if !arrived {
+		fmt.Printf("Timed out waiting for the removal, %s not swapped\n", other)
+		return
+	}
+
+	received := after - before
+
+	getPairs()
+
+	minimum := minReceived(multDiv(out, received, remOther), maxSlippage)
+	if swapOutput(key, other, received) < minimum {
+		fmt.Printf("Reserves moved past the slippage tolerance, holding %f %s\n",
+			d.DeroFormatMoneyPrecision(received, tokens[other].decimals), other)
+		return
+	}
+
+	txid, b = callSwap(key, other, received)
+	if !b {
+		fmt.Printf("Swap failed, holding %f %s\n", d.DeroFormatMoneyPrecision(received, tokens[other].decimals), other)
+		return
+	}
+
+	fmt.Printf("Swap submitted: txid = %s\n", txid)
+}